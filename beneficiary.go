@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	mbuildin "github.com/filecoin-project/go-state-types/builtin"
+	minertypes "github.com/filecoin-project/go-state-types/builtin/v9/miner"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/api/v1api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var msigFromFlag = &cli.StringFlag{
+	Name:  "from",
+	Usage: "发送方是多签地址时使用的签名人",
+}
+
+// sendFromActor 以actor的身份发送消息。actor 是多签时由 --from 指定的签名人发起提案，否则使用actor的公钥地址签名
+func sendFromActor(ctx context.Context, cctx *cli.Context, api v1api.FullNode, actor, to address.Address, method abi.MethodNum, params []byte) (cid.Cid, error) {
+	act, err := api.StateGetActor(ctx, actor, types.EmptyTSK)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("读取 %s 失败: %w", actor, err)
+	}
+
+	if !builtin.IsMultisigActor(act.Code) {
+		from, err := api.StateAccountKey(ctx, actor, types.EmptyTSK)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("查询 %s 的公钥地址失败: %w", actor, err)
+		}
+		return sendMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, &types.Message{
+			From:   from,
+			To:     to,
+			Value:  big.Zero(),
+			Method: method,
+			Params: params,
+		}, false)
+	}
+
+	var signer address.Address
+	if cctx.IsSet(msigFromFlag.Name) {
+		signer, err = parseAddress(cctx.String(msigFromFlag.Name))
+		if err != nil {
+			return cid.Undef, err
+		}
+	} else {
+		signer, err = api.WalletDefaultAddress(ctx)
+		if err != nil {
+			return cid.Undef, xerrors.Errorf("%s 是多签地址，请用 --from 指定签名人: %w", actor, err)
+		}
+	}
+
+	fmt.Printf("%s 是多签地址，由 %s 发起提案，其它签名人需要执行 msig approve\n", actor, signer)
+	proto, err := api.MsigPropose(ctx, actor, to, big.Zero(), signer, uint64(method), params)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("构造多签提案失败: %w", err)
+	}
+	return InteractiveSend(ctx, cctx, api, proto)
+}
+
+// beneficiaryAvailable 受益人剩余的提现额度，受益人是owner时不限制，与链上 BeneficiaryTerm.Available 一致
+func beneficiaryAvailable(mi lapi.MinerInfo, epoch abi.ChainEpoch) (abi.TokenAmount, bool) {
+	if mi.Beneficiary == mi.Owner || mi.BeneficiaryTerm == nil {
+		return big.Zero(), false
+	}
+	t := mi.BeneficiaryTerm
+	if t.Expiration <= epoch {
+		return big.Zero(), true
+	}
+	return big.Max(big.Sub(t.Quota, t.UsedQuota), big.Zero()), true
+}
+
+// withdrawLimit 按受益人额度和有效期计算最多能提现的金额
+func withdrawLimit(mi lapi.MinerInfo, available abi.TokenAmount, epoch abi.ChainEpoch) abi.TokenAmount {
+	if quota, limited := beneficiaryAvailable(mi, epoch); limited {
+		return big.Min(available, quota)
+	}
+	return available
+}
+
+var beneficiaryCmd = &cli.Command{
+	Name:  "beneficiary",
+	Usage: "查看和修改矿工的受益人(FIP-0029)",
+	Subcommands: []*cli.Command{
+		beneficiaryShowCmd,
+		beneficiaryProposeCmd,
+		beneficiaryApproveCmd,
+	},
+}
+
+var beneficiaryShowCmd = &cli.Command{
+	Name:      "show",
+	Usage:     "查看矿工的受益人和待确认的受益人变更",
+	ArgsUsage: "<minerId>",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.Args().First(), err)
+			return err
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)信息失败。 %v\n", maddr, err)
+			return err
+		}
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		epoch := head.Height()
+
+		fmt.Printf("Owner:       %s\n", mi.Owner)
+		fmt.Printf("Beneficiary: %s\n", mi.Beneficiary)
+		if t := mi.BeneficiaryTerm; t != nil && mi.Beneficiary != mi.Owner {
+			remaining, _ := beneficiaryAvailable(mi, epoch)
+			fmt.Printf("Quota:       %s\n", types.FIL(t.Quota))
+			fmt.Printf("UsedQuota:   %s\n", types.FIL(t.UsedQuota))
+			fmt.Printf("Remaining:   %s\n", types.FIL(remaining))
+			fmt.Printf("Expiration:  %s\n", EpochTime(epoch, t.Expiration))
+			if t.Expiration <= epoch {
+				fmt.Println("受益人已过期，不能再提现，需要owner重新指定受益人")
+			}
+		}
+
+		p := mi.PendingBeneficiaryTerm
+		if p == nil {
+			fmt.Println("\n没有待确认的受益人变更")
+			return nil
+		}
+		fmt.Println("\n待确认的受益人变更:")
+		fmt.Printf("NewBeneficiary:        %s\n", p.NewBeneficiary)
+		fmt.Printf("NewQuota:              %s\n", types.FIL(p.NewQuota))
+		fmt.Printf("NewExpiration:         %s\n", EpochTime(epoch, p.NewExpiration))
+		fmt.Printf("ApprovedByNominee:     %t\n", p.ApprovedByNominee)
+		if mi.Beneficiary != mi.Owner {
+			fmt.Printf("ApprovedByBeneficiary: %t\n", p.ApprovedByBeneficiary)
+		}
+		return nil
+	},
+}
+
+var beneficiaryProposeCmd = &cli.Command{
+	Name:      "propose",
+	Usage:     "owner提议修改受益人，需要新受益人(以及当前受益人)执行 beneficiary approve 后生效",
+	ArgsUsage: "<minerId> <beneficiary> <quota (FIL)> <expiration epoch>",
+	Flags: []cli.Flag{
+		msigFromFlag,
+		&cli.BoolFlag{
+			Name:  "overwrite-pending-change",
+			Usage: "覆盖当前待确认的受益人变更",
+		},
+		unsignedOutFlag,
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+		if cctx.NArg() != 4 {
+			fmt.Println("必须指定矿工号、受益人、额度和过期高度")
+			return fmt.Errorf("必须指定矿工号、受益人、额度和过期高度")
+		}
+
+		srv, err := getFullNodeServices(cctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		defer srv.Close() //nolint:errcheck
+		api := srv.FullNodeAPI()
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.Args().Get(0))
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.Args().Get(0), err)
+			return err
+		}
+		na, err := parseAddress(cctx.Args().Get(1))
+		if err != nil {
+			fmt.Println("受益人地址不正确,", err)
+			return err
+		}
+		nominee, err := api.StateLookupID(ctx, na, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("查询受益人 %s 失败，受益人必须已经在链上。 %v\n", na, err)
+			return err
+		}
+		quota, err := types.ParseFIL(cctx.Args().Get(2))
+		if err != nil {
+			fmt.Println("额度不正确,", err)
+			return err
+		}
+		expiration, err := strconv.ParseInt(cctx.Args().Get(3), 10, 64)
+		if err != nil {
+			fmt.Println("过期高度不正确,", err)
+			return err
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)信息失败。 %v\n", maddr, err)
+			return err
+		}
+		if mi.Beneficiary == mi.Owner && nominee == mi.Owner {
+			fmt.Printf("受益人已经是owner %s\n", mi.Owner)
+			return fmt.Errorf("受益人已经是owner")
+		}
+		if p := mi.PendingBeneficiaryTerm; p != nil {
+			fmt.Printf("当前有待确认的受益人变更: %s, 额度 %s, 过期高度 %d\n", p.NewBeneficiary, types.FIL(p.NewQuota), p.NewExpiration)
+			if !cctx.Bool("overwrite-pending-change") {
+				fmt.Println("请核对后使用 --overwrite-pending-change 覆盖")
+				return fmt.Errorf("有待确认的受益人变更")
+			}
+		}
+
+		params, err := actors.SerializeParams(&minertypes.ChangeBeneficiaryParams{
+			NewBeneficiary: nominee,
+			NewQuota:       abi.TokenAmount(quota),
+			NewExpiration:  abi.ChainEpoch(expiration),
+		})
+		if err != nil {
+			fmt.Printf("序列化参数失败，err:%v\n", err)
+			return err
+		}
+
+		msgCid, err := sendFromActor(ctx, cctx, api, mi.Owner, maddr, mbuildin.MethodsMiner.ChangeBeneficiary, params)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if msgCid == cid.Undef {
+			return nil
+		}
+		fmt.Printf("受益人变更提议已发送 %s，需要 %s 执行 beneficiary approve 确认\n", msgCid, nominee)
+		return nil
+	},
+}
+
+var beneficiaryApproveCmd = &cli.Command{
+	Name:      "approve",
+	Usage:     "新受益人或当前受益人确认待确认的受益人变更",
+	ArgsUsage: "<minerId>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "as",
+			Usage: "以谁的身份确认: nominee(新受益人)、beneficiary(当前受益人)，默认选择还没有确认的一方",
+		},
+		msigFromFlag,
+		unsignedOutFlag,
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		srv, err := getFullNodeServices(cctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		defer srv.Close() //nolint:errcheck
+		api := srv.FullNodeAPI()
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.Args().First(), err)
+			return err
+		}
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)信息失败。 %v\n", maddr, err)
+			return err
+		}
+		p := mi.PendingBeneficiaryTerm
+		if p == nil {
+			fmt.Println("没有待确认的受益人变更")
+			return fmt.Errorf("没有待确认的受益人变更")
+		}
+
+		as := cctx.String("as")
+		if as == "" {
+			switch {
+			case !p.ApprovedByNominee && p.NewBeneficiary != mi.Owner:
+				as = "nominee"
+			case !p.ApprovedByBeneficiary && mi.Beneficiary != mi.Owner:
+				as = "beneficiary"
+			default:
+				fmt.Println("受益人变更已经被双方确认")
+				return nil
+			}
+		}
+
+		var approver address.Address
+		switch as {
+		case "nominee":
+			approver = p.NewBeneficiary
+		case "beneficiary":
+			if mi.Beneficiary == mi.Owner {
+				fmt.Println("当前受益人是owner，不需要确认")
+				return fmt.Errorf("当前受益人是owner")
+			}
+			approver = mi.Beneficiary
+		default:
+			fmt.Printf("--as 的值 %s 不正确，可选: nominee, beneficiary\n", as)
+			return fmt.Errorf("--as 的值不正确")
+		}
+
+		fmt.Printf("以 %s %s%s 确认受益人变更: %s, 额度 %s, 过期高度 %d\n", as, approver, addressLabel(approver), p.NewBeneficiary, types.FIL(p.NewQuota), p.NewExpiration)
+
+		params, err := actors.SerializeParams(&minertypes.ChangeBeneficiaryParams{
+			NewBeneficiary: p.NewBeneficiary,
+			NewQuota:       p.NewQuota,
+			NewExpiration:  p.NewExpiration,
+		})
+		if err != nil {
+			fmt.Printf("序列化参数失败，err:%v\n", err)
+			return err
+		}
+
+		msgCid, err := sendFromActor(ctx, cctx, api, approver, maddr, mbuildin.MethodsMiner.ChangeBeneficiary, params)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if msgCid == cid.Undef {
+			return nil
+		}
+		fmt.Printf("受益人变更确认已发送 %s\n", msgCid)
+		return nil
+	},
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithdrawLimit(t *testing.T) {
+	owner, _ := address.NewIDAddress(1000)
+	beneficiary, _ := address.NewIDAddress(1001)
+	available := abi.NewTokenAmount(100)
+
+	mi := lapi.MinerInfo{Owner: owner, Beneficiary: owner}
+	assert.Equal(t, available, withdrawLimit(mi, available, 10))
+
+	mi.Beneficiary = beneficiary
+	mi.BeneficiaryTerm = &miner.BeneficiaryTerm{
+		Quota:      abi.NewTokenAmount(80),
+		UsedQuota:  abi.NewTokenAmount(50),
+		Expiration: 20,
+	}
+	assert.Equal(t, abi.NewTokenAmount(30), withdrawLimit(mi, available, 10))
+	assert.Equal(t, abi.NewTokenAmount(20), withdrawLimit(mi, abi.NewTokenAmount(20), 10))
+	assert.Equal(t, abi.NewTokenAmount(0), withdrawLimit(mi, available, 20))
+}
@@ -45,7 +45,7 @@ var countAvailableFil = &cli.Command{
 	Action: func(cctx *cli.Context) error {
 		color.NoColor = !cctx.Bool("color")
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -195,7 +195,7 @@ var countAvailableFil = &cli.Command{
 		totalAvailable := types.BigInt{}
 		totalAll := types.BigInt{}
 		for _, addr := range targAddrs {
-			a, err := address.NewFromString(addr)
+			a, err := parseAddress(addr)
 			if err != nil {
 				fmt.Println(err)
 				continue
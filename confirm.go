@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+	"golang.org/x/xerrors"
+)
+
+var yesFlag = &cli.BoolFlag{
+	Name:    "yes",
+	Aliases: []string{"really-do-it"},
+	Usage:   "跳过确认直接发送，用于脚本。非交互终端中必须指定",
+}
+
+// errCanceled 用户在确认时取消
+var errCanceled = xerrors.New("取消发送")
+
+// confirmSend 发送前确认，指定 --yes 时跳过，非交互终端中没有 --yes 时拒绝发送
+func confirmSend(cctx *cli.Context) error {
+	if cctx.Bool(yesFlag.Name) {
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Println("当前不是交互终端，请使用 --yes 确认发送")
+		return xerrors.New("非交互终端需要指定 --yes")
+	}
+
+	var sure string
+	fmt.Printf("请核对以上信息，确认发送：yes/n : ")
+	if _, err := fmt.Scanln(&sure); err != nil {
+		fmt.Println("获取终端输入异常，err: ", err)
+		return errCanceled
+	}
+	if sure != "yes" {
+		fmt.Println("取消发送")
+		return errCanceled
+	}
+	return nil
+}
+
+// addressLabel 本地钱包中保存的地址说明，例如 " (本地钱包, owner f01234)"
+func addressLabel(addr address.Address) string {
+	if localdb == nil {
+		return ""
+	}
+
+	b, err := localdb.Get(db.KeyAddr, addr.String())
+	if err != nil {
+		return ""
+	}
+	var fai FilAddressInfo
+	if err := json.Unmarshal(b, &fai); err != nil {
+		return " (本地钱包)"
+	}
+
+	label := " (本地钱包"
+	if fai.AddrType != "" {
+		label += ", " + fai.AddrType
+	}
+	if fai.MinerId != "" {
+		label += " " + fai.MinerId
+	}
+	return label + ")"
+}
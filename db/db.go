@@ -44,6 +44,12 @@ const (
 	KeyIndex  KeyType = "filIndex"
 	KeyCommon KeyType = "commonKey"
 	KeyPriKey KeyType = "filPriKey"
+	KeyMsg    KeyType = "filMsg"
+	KeyNonce  KeyType = "filNonce"
+
+	// 钱包api删除的地址和私钥移到trash下，不直接删除
+	KeyTrashAddr   KeyType = "filTrashAddr"
+	KeyTrashPriKey KeyType = "filTrashPriKey"
 
 	USDTKeyIndex KeyType = "usdtIndex"
 )
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 通过全局参数 --dry-run 只模拟执行消息，不签名也不推送
+var dryRun bool
+
+var dryRunCliFlag = &cli.BoolFlag{
+	Name:        "dry-run",
+	Usage:       "只构造消息并在当前高度上模拟执行，打印执行结果，不需要输入密码，不签名也不推送",
+	Destination: &dryRun,
+}
+
+// simulateMessage 使用 StateCall 在当前tipset上执行消息，执行失败时返回错误
+func simulateMessage(ctx context.Context, api v0api.FullNode, msg *types.Message) error {
+	fmt.Println("模拟执行消息(dry-run)，不会签名和推送")
+
+	res, err := api.StateCall(ctx, msg, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("模拟执行消息失败，err:%v\n", err)
+		return xerrors.Errorf("StateCall error: %w", err)
+	}
+
+	fmt.Printf("退出码: %d (%s)\n", res.MsgRct.ExitCode, res.MsgRct.ExitCode)
+	fmt.Printf("GasUsed: %d\n", res.MsgRct.GasUsed)
+	if len(res.MsgRct.Return) > 0 {
+		fmt.Printf("返回值: %s\n", decodeReturn(ctx, api, msg.To, msg.Method, res.MsgRct.Return))
+	}
+	if res.Error != "" {
+		fmt.Printf("错误: %s\n", res.Error)
+	}
+
+	if res.MsgRct.ExitCode.IsError() {
+		return xerrors.Errorf("message would fail on chain: exit code %d", res.MsgRct.ExitCode)
+	}
+	return nil
+}
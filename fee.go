@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var maxFeeFlag = &cli.StringFlag{
+	Name:  "max-fee",
+	Usage: "单条消息最多支付的手续费(FIL)，估算的手续费超过时降低GasFeeCap",
+}
+
+var gasPremiumFlag = &cli.StringFlag{
+	Name:  "gas-premium",
+	Usage: "指定GasPremium(attoFIL)，不指定时由节点估算",
+}
+
+var gasFeeCapFlag = &cli.StringFlag{
+	Name:  "gas-feecap",
+	Usage: "指定GasFeeCap(attoFIL)，不指定时由节点估算",
+}
+
+var gasLimitFlag = &cli.Int64Flag{
+	Name:  "gas-limit",
+	Usage: "指定GasLimit，不指定时由节点估算",
+}
+
+// feePolicy 手续费策略，可以在 config.json 中配置默认值和每个命令的值，命令行参数优先
+type feePolicy struct {
+	MaxFee     string `json:",omitempty"` // FIL
+	GasPremium string `json:",omitempty"` // attoFIL
+	GasFeeCap  string `json:",omitempty"` // attoFIL
+}
+
+// merge 用p中设置了的值覆盖
+func (fp feePolicy) merge(p feePolicy) feePolicy {
+	if p.MaxFee != "" {
+		fp.MaxFee = p.MaxFee
+	}
+	if p.GasPremium != "" {
+		fp.GasPremium = p.GasPremium
+	}
+	if p.GasFeeCap != "" {
+		fp.GasFeeCap = p.GasFeeCap
+	}
+	return fp
+}
+
+// feeParams 解析后的手续费参数，零值表示由节点估算
+type feeParams struct {
+	MaxFee     abi.TokenAmount
+	GasPremium abi.TokenAmount
+	GasFeeCap  abi.TokenAmount
+	GasLimit   int64
+}
+
+// getFeeParams 按 config.json 的默认策略、命令策略、命令行参数的顺序确定手续费参数
+func getFeeParams(cctx *cli.Context) (*feeParams, error) {
+	cfg, err := loadRepoConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var policy feePolicy
+	if cfg.Fee != nil {
+		policy = *cfg.Fee
+	}
+	if p, ok := cfg.CommandFees[cctx.Command.FullName()]; ok {
+		policy = policy.merge(p)
+	}
+	policy = policy.merge(feePolicy{
+		MaxFee:     cctx.String(maxFeeFlag.Name),
+		GasPremium: cctx.String(gasPremiumFlag.Name),
+		GasFeeCap:  cctx.String(gasFeeCapFlag.Name),
+	})
+
+	return parseFeePolicy(policy, cctx.Int64(gasLimitFlag.Name))
+}
+
+func parseFeePolicy(policy feePolicy, gasLimit int64) (*feeParams, error) {
+	fp := &feeParams{
+		MaxFee:     big.Zero(),
+		GasPremium: big.Zero(),
+		GasFeeCap:  big.Zero(),
+		GasLimit:   gasLimit,
+	}
+
+	if policy.MaxFee != "" {
+		v, err := types.ParseFIL(policy.MaxFee)
+		if err != nil {
+			return nil, xerrors.Errorf("解析max-fee失败: %w", err)
+		}
+		fp.MaxFee = abi.TokenAmount(v)
+	}
+	if policy.GasPremium != "" {
+		v, err := types.BigFromString(policy.GasPremium)
+		if err != nil {
+			return nil, xerrors.Errorf("解析gas-premium失败: %w", err)
+		}
+		fp.GasPremium = v
+	}
+	if policy.GasFeeCap != "" {
+		v, err := types.BigFromString(policy.GasFeeCap)
+		if err != nil {
+			return nil, xerrors.Errorf("解析gas-feecap失败: %w", err)
+		}
+		fp.GasFeeCap = v
+	}
+	if fp.GasLimit < 0 {
+		return nil, xerrors.Errorf("gas-limit不能小于0")
+	}
+	return fp, nil
+}
+
+// apply 把指定的gas参数写入消息，GasEstimateMessageGas 只估算为0的字段
+func (fp *feeParams) apply(msg *types.Message) {
+	if !fp.GasPremium.IsZero() {
+		msg.GasPremium = fp.GasPremium
+	}
+	if !fp.GasFeeCap.IsZero() {
+		msg.GasFeeCap = fp.GasFeeCap
+	}
+	if fp.GasLimit != 0 {
+		msg.GasLimit = fp.GasLimit
+	}
+}
+
+func (fp *feeParams) spec() *lapi.MessageSendSpec {
+	if fp.MaxFee.IsZero() {
+		return nil
+	}
+	return &lapi.MessageSendSpec{MaxFee: fp.MaxFee}
+}
+
+// check 检查估算后的最高手续费没有超过 max-fee
+func (fp *feeParams) check(msg *types.Message) error {
+	if fp.MaxFee.IsZero() {
+		return nil
+	}
+	if fee := worstCaseFee(msg); fee.GreaterThan(fp.MaxFee) {
+		return xerrors.Errorf("最高手续费 %s 超过了 --max-fee %s，请调整 gas-feecap/gas-limit", types.FIL(fee), types.FIL(fp.MaxFee))
+	}
+	return nil
+}
+
+// worstCaseFee 消息最多可能支付的手续费 GasFeeCap * GasLimit
+func worstCaseFee(msg *types.Message) abi.TokenAmount {
+	return big.Mul(msg.GasFeeCap, big.NewInt(msg.GasLimit))
+}
+
+// estimateMessageGas 按手续费参数评估消息的gas
+func estimateMessageGas(ctx context.Context, cctx *cli.Context, api v0api.FullNode, msg *types.Message) (*types.Message, error) {
+	fp, err := getFeeParams(cctx)
+	if err != nil {
+		return nil, err
+	}
+	fp.apply(msg)
+
+	msg, err = api.GasEstimateMessageGas(ctx, msg, fp.spec(), types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("GasEstimateMessageGas error: %w", err)
+	}
+
+	if err := fp.check(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func printFee(msg *types.Message) {
+	fmt.Printf("GasLimit: %d, GasFeeCap: %s, GasPremium: %s\n", msg.GasLimit, msg.GasFeeCap, msg.GasPremium)
+	fmt.Printf("最高手续费: %s\n", types.FIL(worstCaseFee(msg)))
+}
+
+// estimateTransferFee 评估一笔普通转账的最高手续费，用于批量转账前预留手续费
+func estimateTransferFee(ctx context.Context, cctx *cli.Context, api v0api.FullNode, from, to address.Address) (abi.TokenAmount, error) {
+	msg, err := estimateMessageGas(ctx, cctx, api, &types.Message{
+		From:  from,
+		To:    to,
+		Value: big.Zero(),
+	})
+	if err != nil {
+		return big.Zero(), err
+	}
+	return worstCaseFee(msg), nil
+}
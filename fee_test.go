@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeePolicy(t *testing.T) {
+	p := feePolicy{MaxFee: "0.1", GasPremium: "100"}.merge(feePolicy{MaxFee: "0.05"})
+	assert.Equal(t, "0.05", p.MaxFee)
+	assert.Equal(t, "100", p.GasPremium)
+
+	fp, err := parseFeePolicy(p, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, abi.NewTokenAmount(5e16), fp.MaxFee)
+	assert.NotNil(t, fp.spec())
+
+	msg := &types.Message{GasFeeCap: big.NewInt(1e9), GasLimit: 1e7}
+	fp.apply(msg)
+	assert.Equal(t, big.NewInt(100), msg.GasPremium)
+	assert.Equal(t, int64(1e7), msg.GasLimit)
+	assert.NoError(t, fp.check(msg))
+
+	msg.GasLimit = 1e8
+	assert.Error(t, fp.check(msg))
+
+	_, err = parseFeePolicy(feePolicy{GasFeeCap: "abc"}, 0)
+	assert.Error(t, err)
+
+	fp, err = parseFeePolicy(feePolicy{}, 0)
+	assert.NoError(t, err)
+	assert.Nil(t, fp.spec())
+}
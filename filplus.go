@@ -10,10 +10,10 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/blockstore"
-	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/verifreg"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
@@ -48,13 +48,16 @@ var filplusVerifyClientCmd = &cli.Command{
 			Usage:    "specify your notary address to send the message from",
 			Required: true,
 		},
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		/**
 		1 获取nonce，
@@ -72,7 +75,7 @@ var filplusVerifyClientCmd = &cli.Command{
 			return fmt.Errorf("must specify from address with --from")
 		}
 
-		fromk, err := address.NewFromString(froms)
+		fromk, err := parseAddress(froms)
 		if err != nil {
 			return err
 		}
@@ -81,7 +84,7 @@ var filplusVerifyClientCmd = &cli.Command{
 			return fmt.Errorf("must specify two arguments: address and allowance")
 		}
 
-		target, err := address.NewFromString(cctx.Args().Get(0))
+		target, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -91,7 +94,7 @@ var filplusVerifyClientCmd = &cli.Command{
 			return err
 		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -117,57 +120,18 @@ var filplusVerifyClientCmd = &cli.Command{
 			return err
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, fromk, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
-		msg := &types.Message{
+		msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
 			To:     verifreg.Address,
 			From:   fromk,
+			Value:  big.Zero(),
 			Method: verifreg.Methods.AddVerifiedClient,
 			Params: params,
-			Nonce: a.Nonce,
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
+		}, false)
 		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
-
-		//smsg, err := api.MpoolPushMessage(ctx, msg, nil)
-		//if err != nil {
-		//	return err
-		//}
-
-		//fmt.Printf("message sent, now waiting on cid: %s\n", smsg.Cid())
-
-		mwait, err := api.StateWaitMsg(ctx, cid, build.MessageConfidence)
-		if err != nil {
-			return err
-		}
-
-		if mwait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("failed to add verified client: %d", mwait.Receipt.ExitCode)
+		if msgCid == cid.Undef {
+			return nil
 		}
 
 		return nil
@@ -178,7 +142,7 @@ var filplusListNotariesCmd = &cli.Command{
 	Name:  "list-notaries",
 	Usage: "list all notaries",
 	Action: func(cctx *cli.Context) error {
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -208,7 +172,7 @@ var filplusListClientsCmd = &cli.Command{
 	Name:  "list-clients",
 	Usage: "list all verified clients",
 	Action: func(cctx *cli.Context) error {
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -242,12 +206,12 @@ var filplusCheckClientCmd = &cli.Command{
 			return fmt.Errorf("must specify client address to check")
 		}
 
-		caddr, err := address.NewFromString(cctx.Args().First())
+		caddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			return err
 		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -276,12 +240,12 @@ var filplusCheckNotaryCmd = &cli.Command{
 			return fmt.Errorf("must specify notary address to check")
 		}
 
-		vaddr, err := address.NewFromString(cctx.Args().First())
+		vaddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			return err
 		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -339,7 +303,7 @@ var filplusSignRemoveDataCapProposal = &cli.Command{
 			return fmt.Errorf("must specify three arguments: notary address, client address, and allowance to remove")
 		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return xerrors.Errorf("failed to get full node api: %w", err)
 		}
@@ -359,7 +323,7 @@ var filplusSignRemoveDataCapProposal = &cli.Command{
 			return xerrors.Errorf("failed to load verified registry state: %w", err)
 		}
 
-		verifier, err := address.NewFromString(cctx.Args().Get(0))
+		verifier, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -368,7 +332,7 @@ var filplusSignRemoveDataCapProposal = &cli.Command{
 			return err
 		}
 
-		client, err := address.NewFromString(cctx.Args().Get(1))
+		client, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// fundThreshold 余额低于 Min 时补充到 Target，Target 为空时补充到 Min，单位FIL
+type fundThreshold struct {
+	Min    string
+	Target string `json:",omitempty"`
+}
+
+// fundControlConfig config.json 中 fund-control 的默认配置，命令行参数优先
+type fundControlConfig struct {
+	From    string
+	Miners  []string       `json:",omitempty"`
+	Worker  *fundThreshold `json:",omitempty"` // worker 和待生效的 NewWorker
+	Control *fundThreshold `json:",omitempty"`
+}
+
+type fundLevel struct {
+	Min    abi.TokenAmount
+	Target abi.TokenAmount
+}
+
+func parseFundThreshold(role string, th *fundThreshold) (*fundLevel, error) {
+	if th == nil || th.Min == "" {
+		return nil, nil
+	}
+	min, err := types.ParseFIL(th.Min)
+	if err != nil {
+		return nil, xerrors.Errorf("%s 的下限不正确: %w", role, err)
+	}
+	l := &fundLevel{Min: abi.TokenAmount(min), Target: abi.TokenAmount(min)}
+	if th.Target != "" {
+		target, err := types.ParseFIL(th.Target)
+		if err != nil {
+			return nil, xerrors.Errorf("%s 的补充目标不正确: %w", role, err)
+		}
+		if abi.TokenAmount(target).LessThan(l.Min) {
+			return nil, xerrors.Errorf("%s 的补充目标不能小于下限", role)
+		}
+		l.Target = abi.TokenAmount(target)
+	}
+	return l, nil
+}
+
+// fundAmount 余额低于下限时需要补充的金额，不需要补充时返回0
+func fundAmount(balance abi.TokenAmount, l *fundLevel) abi.TokenAmount {
+	if !balance.LessThan(l.Min) {
+		return big.Zero()
+	}
+	return big.Sub(l.Target, balance)
+}
+
+// readMinerList 读取矿工列表文件，矿工号用分号或空白分隔，如 f02420;f0144528;
+func readMinerList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return strings.FieldsFunc(string(data), func(r rune) bool {
+		return r == ';' || r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t'
+	}), nil
+}
+
+// fundTarget 一个需要检查余额的地址，同一个地址可能是多个矿工的控制地址
+type fundTarget struct {
+	Addr    address.Address
+	Roles   []string
+	Level   *fundLevel
+	Balance abi.TokenAmount
+	Amount  abi.TokenAmount
+}
+
+func (t *fundTarget) add(role string, l *fundLevel) {
+	t.Roles = append(t.Roles, role)
+	if t.Level == nil {
+		t.Level = &fundLevel{Min: l.Min, Target: l.Target}
+		return
+	}
+	t.Level.Min = big.Max(t.Level.Min, l.Min)
+	t.Level.Target = big.Max(t.Level.Target, l.Target)
+}
+
+// planFunding 读取所有矿工的worker、待生效的NewWorker和控制地址的余额，计算需要补充的金额
+func planFunding(ctx context.Context, api v0api.FullNode, miners []address.Address, worker, control *fundLevel) ([]*fundTarget, error) {
+	targets := map[address.Address]*fundTarget{}
+	add := func(addr address.Address, role string, l *fundLevel) {
+		if l == nil || addr == address.Undef {
+			return
+		}
+		t, ok := targets[addr]
+		if !ok {
+			t = &fundTarget{Addr: addr}
+			targets[addr] = t
+		}
+		t.add(role, l)
+	}
+
+	for _, maddr := range miners {
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return nil, xerrors.Errorf("读取矿工 %s 信息失败: %w", maddr, err)
+		}
+		add(mi.Worker, maddr.String()+" worker", worker)
+		add(mi.NewWorker, maddr.String()+" new-worker", worker)
+		for _, ca := range mi.ControlAddresses {
+			add(ca, maddr.String()+" control", control)
+		}
+	}
+
+	var out []*fundTarget
+	for _, t := range targets {
+		b, err := api.WalletBalance(ctx, t.Addr)
+		if err != nil {
+			return nil, xerrors.Errorf("读取 %s 余额失败: %w", t.Addr, err)
+		}
+		t.Balance = b
+		t.Amount = fundAmount(b, t.Level)
+		out = append(out, t)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Addr.String() < out[j].Addr.String() })
+	return out, nil
+}
+
+var fundControlCmd = &cli.Command{
+	Name:      "fund-control",
+	Usage:     "检查所有矿工的worker、NewWorker和控制地址余额，低于下限时从资金地址补充",
+	ArgsUsage: "[miners.txt]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "资金地址，默认使用 config.json 中 FundControl.From",
+		},
+		&cli.StringSliceFlag{
+			Name:  "miner",
+			Usage: "要检查的矿工，可以指定多次，也可以通过 miners.txt 或 config.json 中 FundControl.Miners 指定",
+		},
+		&cli.StringFlag{
+			Name:  "worker-min",
+			Usage: "worker 和 NewWorker 的余额下限(FIL)",
+		},
+		&cli.StringFlag{
+			Name:  "worker-target",
+			Usage: "worker 和 NewWorker 补充到的余额(FIL)，默认等于下限",
+		},
+		&cli.StringFlag{
+			Name:  "control-min",
+			Usage: "控制地址(PoSt)的余额下限(FIL)",
+		},
+		&cli.StringFlag{
+			Name:  "control-target",
+			Usage: "控制地址补充到的余额(FIL)，默认等于下限",
+		},
+		&cli.DurationFlag{
+			Name:  "loop",
+			Usage: "循环检查的间隔，例如 10m，用于 systemd 常驻运行，非交互终端中需要同时指定 --yes",
+		},
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		cfg, err := loadRepoConfig()
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		fc := fundControlConfig{}
+		if cfg.FundControl != nil {
+			fc = *cfg.FundControl
+		}
+		if v := cctx.String("from"); v != "" {
+			fc.From = v
+		}
+		if cctx.IsSet("miner") || cctx.Args().Present() {
+			fc.Miners = cctx.StringSlice("miner")
+		}
+		if cctx.Args().Present() {
+			list, err := readMinerList(cctx.Args().First())
+			if err != nil {
+				fmt.Println("读取矿工列表失败,", err)
+				return err
+			}
+			fc.Miners = append(fc.Miners, list...)
+		}
+		if v := cctx.String("worker-min"); v != "" {
+			fc.Worker = &fundThreshold{Min: v, Target: cctx.String("worker-target")}
+		}
+		if v := cctx.String("control-min"); v != "" {
+			fc.Control = &fundThreshold{Min: v, Target: cctx.String("control-target")}
+		}
+
+		if fc.From == "" {
+			fmt.Println("必须通过 --from 或 config.json 指定资金地址")
+			return fmt.Errorf("必须指定资金地址")
+		}
+		from, err := parseAddress(fc.From)
+		if err != nil {
+			fmt.Println("资金地址不正确,", err)
+			return err
+		}
+		if len(fc.Miners) == 0 {
+			fmt.Println("必须指定要检查的矿工")
+			return fmt.Errorf("必须指定要检查的矿工")
+		}
+		var miners []address.Address
+		for _, m := range fc.Miners {
+			maddr, err := parseAddress(m)
+			if err != nil {
+				fmt.Printf("矿工号 %s 不正确, %v\n", m, err)
+				return err
+			}
+			miners = append(miners, maddr)
+		}
+
+		worker, err := parseFundThreshold("worker", fc.Worker)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		control, err := parseFundThreshold("control", fc.Control)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if worker == nil && control == nil {
+			fmt.Println("必须至少指定 worker 或 control 的余额下限")
+			return fmt.Errorf("没有指定余额下限")
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		interval := cctx.Duration("loop")
+		for {
+			err := fundControlRound(ctx, cctx, api, from, miners, worker, control, interval > 0)
+			if interval <= 0 {
+				return err
+			}
+			if err != nil {
+				fmt.Printf("%s 本轮补充失败，err: %v\n", time.Now().Format(time.DateTime), err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+	},
+}
+
+// fundControlRound 检查一轮余额，需要补充的地址作为一批确认后发送。wait 为 true 时等待消息上链，避免下一轮重复补充
+func fundControlRound(ctx context.Context, cctx *cli.Context, api v0api.FullNode, from address.Address, miners []address.Address, worker, control *fundLevel, wait bool) error {
+	fmt.Printf("\n%s 检查 %d 个矿工的地址余额\n", time.Now().Format(time.DateTime), len(miners))
+
+	targets, err := planFunding(ctx, api, miners, worker, control)
+	if err != nil {
+		return err
+	}
+
+	tw := tablewriter.New(
+		tablewriter.Col("Address"),
+		tablewriter.Col("Balance"),
+		tablewriter.Col("Min"),
+		tablewriter.Col("Target"),
+		tablewriter.Col("TopUp"),
+		tablewriter.NewLineCol("Roles"))
+
+	total := big.Zero()
+	var todo []*fundTarget
+	for _, t := range targets {
+		row := map[string]interface{}{
+			"Address": t.Addr,
+			"Balance": types.FIL(t.Balance),
+			"Min":     types.FIL(t.Level.Min),
+			"Target":  types.FIL(t.Level.Target),
+			"Roles":   strings.Join(t.Roles, ", "),
+		}
+		if t.Amount.GreaterThan(big.Zero()) {
+			row["TopUp"] = types.FIL(t.Amount)
+			total = big.Add(total, t.Amount)
+			todo = append(todo, t)
+		}
+		tw.Write(row)
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	if len(todo) == 0 {
+		fmt.Println("所有地址余额充足，不需要补充")
+		return nil
+	}
+
+	fee, err := estimateTransferFee(ctx, cctx, api, from, todo[0].Addr)
+	if err != nil {
+		return err
+	}
+	need := big.Add(total, big.Mul(fee, big.NewInt(int64(len(todo)))))
+	fb, err := api.WalletBalance(ctx, from)
+	if err != nil {
+		return xerrors.Errorf("读取资金地址余额失败: %w", err)
+	}
+	fmt.Printf("需要补充 %d 个地址，合计 %s，最高手续费合计 %s，资金地址 %s%s 余额 %s\n",
+		len(todo), types.FIL(total), types.FIL(big.Sub(need, total)), from, addressLabel(from), types.FIL(fb))
+	if fb.LessThan(need) {
+		return xerrors.Errorf("资金地址余额不足，需要 %s", types.FIL(need))
+	}
+
+	if !dryRun {
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+	}
+
+	var cids []cid.Cid
+	for _, t := range todo {
+		c, err := send(cctx, api, from, t.Addr.String(), types.FIL(t.Amount).Unitless(), nil)
+		if err != nil {
+			return xerrors.Errorf("补充 %s 失败: %w", t.Addr, err)
+		}
+		if dryRun {
+			continue
+		}
+		fmt.Printf("%s -> %s %s, Message CID: %s\n", from, t.Addr, types.FIL(t.Amount), c)
+		cids = append(cids, c)
+	}
+
+	if wait {
+		for _, c := range cids {
+			if _, err := waitMessage(ctx, cctx, api, c); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
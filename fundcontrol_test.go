@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFundAmount(t *testing.T) {
+	l, err := parseFundThreshold("control", &fundThreshold{Min: "5", Target: "20"})
+	assert.NoError(t, err)
+
+	fil := func(s string) abi.TokenAmount { return abi.TokenAmount(types.MustParseFIL(s)) }
+	assert.Equal(t, fil("17"), fundAmount(fil("3"), l))
+	assert.Equal(t, big.Zero(), fundAmount(fil("5"), l))
+
+	l, err = parseFundThreshold("worker", &fundThreshold{Min: "5"})
+	assert.NoError(t, err)
+	assert.Equal(t, fil("1"), fundAmount(fil("4"), l))
+
+	_, err = parseFundThreshold("worker", &fundThreshold{Min: "5", Target: "1"})
+	assert.Error(t, err)
+
+	l, err = parseFundThreshold("worker", nil)
+	assert.NoError(t, err)
+	assert.Nil(t, l)
+}
+
+func TestReadMinerList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "miners.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("f02420;f0144528;\nf01000\n"), 0644))
+
+	miners, err := readMinerList(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f02420", "f0144528", "f01000"}, miners)
+}
@@ -158,22 +158,28 @@ func generateSecp256addr(priKey *ecdsa.PrivateKey) (string, error) {
 }
 
 func generateBlsAddr(priv []byte) (string, error) {
+	pubkey, err := blsPublicKey(priv)
+	if err != nil {
+		return "", err
+	}
+
+	blsaddr, err := address.NewBLSAddress(pubkey)
+	if err != nil {
+		return "", err
+	}
+	return blsaddr.String(), nil
+}
+
+func blsPublicKey(priv []byte) ([]byte, error) {
 	if priv == nil || len(priv) != ffi.PrivateKeyBytes {
-		return "", fmt.Errorf("bls signature invalid private key")
+		return nil, fmt.Errorf("bls signature invalid private key")
 	}
 
 	sk := new([32]byte)
 	copy(sk[:], priv[:ffi.PrivateKeyBytes])
 
 	pubkey := ffi.PrivateKeyPublicKey(*sk)
-
-	//return pubkey[:], nil
-
-	blsaddr, err := address.NewBLSAddress(pubkey[:])
-	if err != nil {
-		return "", err
-	}
-	return blsaddr.String(), nil
+	return pubkey[:], nil
 }
 
 func Sign(msg []byte, addr address.Address, mnenoic string, index int) (*crypto.Signature, error) {
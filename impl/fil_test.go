@@ -63,7 +63,7 @@ func TestBlsSign(t *testing.T) {
 
 func TestGenrateSpec256(t *testing.T)  {
 	
-	//mn := "tooth close faith twenty budget fame cheap island  canal make item"
+	mn := "tooth close faith twenty budget fame cheap island  canal make item"
 
 	// pk, err := ExportSecp256k1Address(mn, 0)
 	pk, err := CreateSecp256k1FilAddress(mn, 0)
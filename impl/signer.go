@@ -0,0 +1,298 @@
+package impl
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	crypto2 "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/blake2b"
+	"github.com/filecoin-project/firefly-wallet/mnemonic"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/chain/types"
+	cliutil "github.com/filecoin-project/lotus/cli/util"
+	"golang.org/x/xerrors"
+)
+
+// Signer 签名后端，助记词派生地址、导入的私钥、远程钱包都实现这个接口
+type Signer interface {
+	// Addresses 返回这个后端可以签名的所有地址
+	Addresses(ctx context.Context) ([]address.Address, error)
+	// Sign 使用addr对应的私钥对msg签名
+	Sign(ctx context.Context, addr address.Address, msg []byte) (*crypto.Signature, error)
+	// PublicKey 返回addr对应的公钥
+	PublicKey(ctx context.Context, addr address.Address) ([]byte, error)
+}
+
+// SignerRegistry 记录地址到签名后端的映射，先注册的后端优先
+type SignerRegistry struct {
+	lk       sync.RWMutex
+	backends map[address.Address]Signer
+}
+
+var _ Signer = (*SignerRegistry)(nil)
+
+func NewSignerRegistry() *SignerRegistry {
+	return &SignerRegistry{backends: map[address.Address]Signer{}}
+}
+
+// Register 把后端能签名的地址都登记到registry，已经登记过的地址保持原来的后端
+func (r *SignerRegistry) Register(ctx context.Context, s Signer) error {
+	addrs, err := s.Addresses(ctx)
+	if err != nil {
+		return xerrors.Errorf("listing signer addresses: %w", err)
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	for _, addr := range addrs {
+		if _, ok := r.backends[addr]; ok {
+			continue
+		}
+		r.backends[addr] = s
+	}
+	return nil
+}
+
+// Unregister 从registry中移除addr
+func (r *SignerRegistry) Unregister(addr address.Address) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	delete(r.backends, addr)
+}
+
+// Lookup 查找addr对应的签名后端
+func (r *SignerRegistry) Lookup(addr address.Address) (Signer, bool) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+	s, ok := r.backends[addr]
+	return s, ok
+}
+
+func (r *SignerRegistry) Addresses(ctx context.Context) ([]address.Address, error) {
+	r.lk.RLock()
+	defer r.lk.RUnlock()
+	out := make([]address.Address, 0, len(r.backends))
+	for addr := range r.backends {
+		out = append(out, addr)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].String() < out[j].String()
+	})
+	return out, nil
+}
+
+func (r *SignerRegistry) Sign(ctx context.Context, addr address.Address, msg []byte) (*crypto.Signature, error) {
+	s, ok := r.Lookup(addr)
+	if !ok {
+		return nil, xerrors.Errorf("no signer for address %s", addr)
+	}
+	return s.Sign(ctx, addr, msg)
+}
+
+func (r *SignerRegistry) PublicKey(ctx context.Context, addr address.Address) ([]byte, error) {
+	s, ok := r.Lookup(addr)
+	if !ok {
+		return nil, xerrors.Errorf("no signer for address %s", addr)
+	}
+	return s.PublicKey(ctx, addr)
+}
+
+// HDSigner 助记词派生地址的签名后端
+type HDSigner struct {
+	mnemonic string
+	indexes  map[address.Address]int
+}
+
+func NewHDSigner(mnemonic string) *HDSigner {
+	return &HDSigner{mnemonic: mnemonic, indexes: map[address.Address]int{}}
+}
+
+// Add 登记一个派生地址及其派生index
+func (s *HDSigner) Add(addr address.Address, index int) {
+	s.indexes[addr] = index
+}
+
+func (s *HDSigner) Addresses(ctx context.Context) ([]address.Address, error) {
+	out := make([]address.Address, 0, len(s.indexes))
+	for addr := range s.indexes {
+		out = append(out, addr)
+	}
+	return out, nil
+}
+
+func (s *HDSigner) Sign(ctx context.Context, addr address.Address, msg []byte) (*crypto.Signature, error) {
+	index, ok := s.indexes[addr]
+	if !ok {
+		return nil, xerrors.Errorf("address %s is not derived from the mnemonic", addr)
+	}
+	return Sign(msg, addr, s.mnemonic, index)
+}
+
+func (s *HDSigner) PublicKey(ctx context.Context, addr address.Address) ([]byte, error) {
+	index, ok := s.indexes[addr]
+	if !ok {
+		return nil, xerrors.Errorf("address %s is not derived from the mnemonic", addr)
+	}
+
+	if addr.Protocol() == address.BLS {
+		privKey, err := generateBLSPriviteKey(s.mnemonic, index)
+		if err != nil {
+			return nil, err
+		}
+		return blsPublicKey(privKey[:])
+	}
+
+	priKey, err := generateSecp256k1PriviteKey(s.mnemonic, index)
+	if err != nil {
+		return nil, err
+	}
+	return crypto2.FromECDSAPub(&priKey.PublicKey), nil
+}
+
+// ImportedKeySigner 导入私钥的签名后端，私钥加密保存，签名时才解密
+type ImportedKeySigner struct {
+	passwd []byte
+	keys   map[address.Address][]byte
+}
+
+func NewImportedKeySigner(passwd []byte) *ImportedKeySigner {
+	return &ImportedKeySigner{passwd: passwd, keys: map[address.Address][]byte{}}
+}
+
+// Add 登记一个导入地址及其加密后的私钥
+func (s *ImportedKeySigner) Add(addr address.Address, encrypted []byte) {
+	s.keys[addr] = encrypted
+}
+
+func (s *ImportedKeySigner) Addresses(ctx context.Context) ([]address.Address, error) {
+	out := make([]address.Address, 0, len(s.keys))
+	for addr := range s.keys {
+		out = append(out, addr)
+	}
+	return out, nil
+}
+
+func (s *ImportedKeySigner) keyInfo(addr address.Address) (*types.KeyInfo, error) {
+	encrypted, ok := s.keys[addr]
+	if !ok {
+		return nil, xerrors.Errorf("address %s is not an imported key", addr)
+	}
+
+	data, err := mnemonic.Decrypt(encrypted, s.passwd)
+	if err != nil {
+		return nil, xerrors.Errorf("decrypting key: %w", err)
+	}
+	return ParseKeyInfo(data)
+}
+
+func (s *ImportedKeySigner) Sign(ctx context.Context, addr address.Address, msg []byte) (*crypto.Signature, error) {
+	ki, err := s.keyInfo(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ki.Type {
+	case types.KTSecp256k1:
+		priKey, err := crypto2.ToECDSA(ki.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		b2sum := blake2b.Sum256(msg)
+		sig, err := crypto2.Sign(b2sum[:], priKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return &crypto.Signature{
+			Type: crypto.SigTypeSecp256k1,
+			Data: sig,
+		}, nil
+	case types.KTBLS:
+		return SignBls(ki.PrivateKey, msg)
+	default:
+		return nil, xerrors.Errorf("unsupported key type: %s", ki.Type)
+	}
+}
+
+func (s *ImportedKeySigner) PublicKey(ctx context.Context, addr address.Address) ([]byte, error) {
+	ki, err := s.keyInfo(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ki.Type {
+	case types.KTSecp256k1:
+		priKey, err := crypto2.ToECDSA(ki.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		return crypto2.FromECDSAPub(&priKey.PublicKey), nil
+	case types.KTBLS:
+		return blsPublicKey(ki.PrivateKey)
+	default:
+		return nil, xerrors.Errorf("unsupported key type: %s", ki.Type)
+	}
+}
+
+// ParseKeyInfo 解析导入的私钥，支持hex-lotus和json-lotus两种格式
+func ParseKeyInfo(data []byte) (*types.KeyInfo, error) {
+	var ki types.KeyInfo
+	raw := []byte(strings.TrimSpace(string(data)))
+	if decoded, err := hex.DecodeString(string(raw)); err == nil {
+		raw = decoded
+	}
+
+	if err := json.Unmarshal(raw, &ki); err != nil {
+		return nil, xerrors.Errorf("unmarshaling key info: %w", err)
+	}
+	return &ki, nil
+}
+
+// RemoteSigner 通过lotus钱包JSON-RPC接口(lotus-wallet, lotus daemon)签名
+type RemoteSigner struct {
+	wallet api.Wallet
+}
+
+func NewRemoteSigner(wallet api.Wallet) *RemoteSigner {
+	return &RemoteSigner{wallet: wallet}
+}
+
+// DialRemoteSigner 连接远程钱包，apiInfo 格式与 FULLNODE_API_INFO 相同: token:multiaddr
+func DialRemoteSigner(ctx context.Context, apiInfo string) (*RemoteSigner, jsonrpc.ClientCloser, error) {
+	ainfo := cliutil.ParseApiInfo(apiInfo)
+	addr, err := ainfo.DialArgs("v0")
+	if err != nil {
+		return nil, nil, xerrors.Errorf("parsing remote wallet api info: %w", err)
+	}
+
+	wallet, closer, err := client.NewWalletRPCV0(ctx, addr, ainfo.AuthHeader())
+	if err != nil {
+		return nil, nil, xerrors.Errorf("connecting to remote wallet: %w", err)
+	}
+	return NewRemoteSigner(wallet), closer, nil
+}
+
+func (s *RemoteSigner) Addresses(ctx context.Context) ([]address.Address, error) {
+	return s.wallet.WalletList(ctx)
+}
+
+func (s *RemoteSigner) Sign(ctx context.Context, addr address.Address, msg []byte) (*crypto.Signature, error) {
+	return s.wallet.WalletSign(ctx, addr, msg, api.MsgMeta{Type: api.MTUnknown})
+}
+
+// PublicKey 远程钱包不提供公钥查询，只有bls地址可以从地址本身得到公钥
+func (s *RemoteSigner) PublicKey(ctx context.Context, addr address.Address) ([]byte, error) {
+	if addr.Protocol() == address.BLS {
+		return addr.Payload(), nil
+	}
+	return nil, xerrors.Errorf("remote signer cannot export public key of %s", addr)
+}
@@ -0,0 +1,110 @@
+package impl
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// stubWallet 模拟一个远程lotus钱包
+type stubWallet struct {
+	addr address.Address
+}
+
+func (w *stubWallet) WalletNew(context.Context, types.KeyType) (address.Address, error) {
+	return address.Undef, nil
+}
+
+func (w *stubWallet) WalletHas(_ context.Context, a address.Address) (bool, error) {
+	return a == w.addr, nil
+}
+
+func (w *stubWallet) WalletList(context.Context) ([]address.Address, error) {
+	return []address.Address{w.addr}, nil
+}
+
+func (w *stubWallet) WalletSign(_ context.Context, a address.Address, msg []byte, _ api.MsgMeta) (*crypto.Signature, error) {
+	return &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: append([]byte("stub:"), msg...)}, nil
+}
+
+func (w *stubWallet) WalletExport(context.Context, address.Address) (*types.KeyInfo, error) {
+	return nil, nil
+}
+
+func (w *stubWallet) WalletImport(context.Context, *types.KeyInfo) (address.Address, error) {
+	return address.Undef, nil
+}
+
+func (w *stubWallet) WalletDelete(context.Context, address.Address) error {
+	return nil
+}
+
+func TestRemoteSigner(t *testing.T) {
+	addr, err := address.NewIDAddress(1000)
+	assert.Nil(t, err)
+
+	rpcServer := jsonrpc.NewServer()
+	rpcServer.Register("Filecoin", &stubWallet{addr: addr})
+	srv := httptest.NewServer(rpcServer)
+	defer srv.Close()
+
+	ctx := context.Background()
+	wallet, closer, err := client.NewWalletRPCV0(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/rpc/v0", nil)
+	assert.Nil(t, err)
+	defer closer()
+
+	registry := NewSignerRegistry()
+	assert.Nil(t, registry.Register(ctx, NewRemoteSigner(wallet)))
+
+	addrs, err := registry.Addresses(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []address.Address{addr}, addrs)
+
+	sig, err := registry.Sign(ctx, addr, []byte("potato"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("stub:potato"), sig.Data)
+
+	_, err = registry.PublicKey(ctx, addr)
+	assert.NotNil(t, err)
+
+	registry.Unregister(addr)
+	_, err = registry.Sign(ctx, addr, []byte("potato"))
+	assert.NotNil(t, err)
+}
+
+func TestSignerRegistryPriority(t *testing.T) {
+	mn := "tag volcano eight thank tide danger coast health above argue embrace heavy"
+
+	fa, err := CreateSecp256k1FilAddress(mn, 0)
+	assert.Nil(t, err)
+	addr, err := address.NewFromString(fa)
+	assert.Nil(t, err)
+
+	hd := NewHDSigner(mn)
+	hd.Add(addr, 0)
+
+	ctx := context.Background()
+	registry := NewSignerRegistry()
+	assert.Nil(t, registry.Register(ctx, hd))
+	assert.Nil(t, registry.Register(ctx, NewRemoteSigner(&stubWallet{addr: addr})))
+
+	s, ok := registry.Lookup(addr)
+	assert.True(t, ok)
+	assert.Equal(t, hd, s)
+
+	pub, err := registry.PublicKey(ctx, addr)
+	assert.Nil(t, err)
+	assert.Len(t, pub, PublicKeyBytes)
+
+	_, ok = registry.Lookup(address.TestAddress)
+	assert.False(t, ok)
+}
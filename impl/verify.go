@@ -0,0 +1,58 @@
+package impl
+
+import (
+	"github.com/ethereum/go-ethereum/crypto/blake2b"
+	"github.com/filecoin-project/go-address"
+	gocrypto "github.com/filecoin-project/go-crypto"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/lib/sigs"
+	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
+	_ "github.com/filecoin-project/lotus/lib/sigs/delegated"
+	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
+	"golang.org/x/crypto/sha3"
+	"golang.org/x/xerrors"
+)
+
+// DecodeSignature 解析sign命令输出的签名格式: [sigType|data]
+func DecodeSignature(b []byte) (*crypto.Signature, error) {
+	var sig crypto.Signature
+	if err := sig.UnmarshalBinary(b); err != nil {
+		return nil, xerrors.Errorf("decoding signature: %w", err)
+	}
+	return &sig, nil
+}
+
+// VerifySignature 验证sig是否为addr对msg的签名
+func VerifySignature(sig *crypto.Signature, addr address.Address, msg []byte) error {
+	return sigs.Verify(sig, addr, msg)
+}
+
+// RecoverSigner 从secp256k1和delegated签名中恢复签名者地址，bls签名无法恢复
+func RecoverSigner(sig *crypto.Signature, msg []byte) (address.Address, error) {
+	switch sig.Type {
+	case crypto.SigTypeSecp256k1:
+		b2sum := blake2b.Sum256(msg)
+		pubk, err := gocrypto.EcRecover(b2sum[:], sig.Data)
+		if err != nil {
+			return address.Undef, err
+		}
+		return address.NewSecp256k1Address(pubk)
+	case crypto.SigTypeDelegated:
+		hasher := sha3.NewLegacyKeccak256()
+		hasher.Write(msg)
+		pubk, err := gocrypto.EcRecover(hasher.Sum(nil), sig.Data)
+		if err != nil {
+			return address.Undef, err
+		}
+		if pubk[0] == 0x04 {
+			pubk = pubk[1:]
+		}
+
+		hasher.Reset()
+		hasher.Write(pubk)
+		return address.NewDelegatedAddress(builtin.EthereumAddressManagerActorID, hasher.Sum(nil)[12:])
+	default:
+		return address.Undef, xerrors.Errorf("cannot recover signer from signature type %d", sig.Type)
+	}
+}
@@ -0,0 +1,35 @@
+package impl
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySignature(t *testing.T) {
+	mn := "tag volcano eight thank tide danger coast health above argue embrace heavy"
+
+	fa, err := CreateSecp256k1FilAddress(mn, 0)
+	assert.Nil(t, err)
+	addr, err := address.NewFromString(fa)
+	assert.Nil(t, err)
+
+	msg := []byte("potato")
+	sig, err := Sign(msg, addr, mn, 0)
+	assert.Nil(t, err)
+
+	b, err := sig.MarshalBinary()
+	assert.Nil(t, err)
+	decoded, err := DecodeSignature(b)
+	assert.Nil(t, err)
+	assert.Equal(t, crypto.SigTypeSecp256k1, decoded.Type)
+
+	assert.Nil(t, VerifySignature(decoded, addr, msg))
+	assert.NotNil(t, VerifySignature(decoded, addr, []byte("tomato")))
+
+	recovered, err := RecoverSigner(decoded, msg)
+	assert.Nil(t, err)
+	assert.Equal(t, addr, recovered)
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 消息状态
+const (
+	msgPending  = "pending"
+	msgExecuted = "executed"
+	msgFailed   = "failed"
+	msgReplaced = "replaced"
+	msgDropped  = "dropped"
+	// nonce已经被使用，但是找不到使用这个nonce的消息，无法确认原消息是否执行
+	msgUnknown = "unknown"
+)
+
+// msgRecord 本工具推送过的消息，保存在数据库 KeyMsg 下，key为消息cid
+type msgRecord struct {
+	Cid        string
+	From       string
+	Nonce      uint64
+	To         string
+	Value      string
+	Method     uint64
+	Command    string
+	Time       time.Time
+	Status     string
+	ExitCode   int64
+	Height     int64
+	Replaces   string `json:",omitempty"`
+	ReplacedBy string `json:",omitempty"`
+}
+
+// recordMessage 记录推送的消息，replaces 为被替换的原消息
+func recordMessage(cctx *cli.Context, smsg *types.SignedMessage, replaces cid.Cid) {
+	if localdb == nil {
+		return
+	}
+
+	rec := &msgRecord{
+		Cid:     smsg.Cid().String(),
+		From:    smsg.Message.From.String(),
+		Nonce:   smsg.Message.Nonce,
+		To:      smsg.Message.To.String(),
+		Value:   types.FIL(smsg.Message.Value).String(),
+		Method:  uint64(smsg.Message.Method),
+		Command: cctx.Command.FullName(),
+		Time:    time.Now(),
+		Status:  msgPending,
+	}
+
+	if replaces != cid.Undef {
+		rec.Replaces = replaces.String()
+		if old, err := getMsgRecord(replaces.String()); err == nil {
+			old.Status = msgReplaced
+			old.ReplacedBy = rec.Cid
+			if err := saveMsgRecord(old); err != nil {
+				fmt.Printf("更新消息记录(%s)失败，err: %v\n", old.Cid, err)
+			}
+		}
+	}
+
+	if err := saveMsgRecord(rec); err != nil {
+		fmt.Printf("保存消息记录(%s)失败，err: %v\n", rec.Cid, err)
+	}
+}
+
+func saveMsgRecord(rec *msgRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeyMsg, rec.Cid, b)
+}
+
+func getMsgRecord(c string) (*msgRecord, error) {
+	b, err := localdb.Get(db.KeyMsg, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var rec msgRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// listMsgRecords 按推送时间倒序返回所有消息记录
+func listMsgRecords() ([]*msgRecord, error) {
+	all, err := localdb.GetAll(db.KeyMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]*msgRecord, 0, len(all))
+	for _, v := range all {
+		var rec msgRecord
+		if err := json.Unmarshal([]byte(v), &rec); err != nil {
+			continue
+		}
+		recs = append(recs, &rec)
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		return recs[i].Time.After(recs[j].Time)
+	})
+	return recs, nil
+}
+
+// refreshMsgRecord 从链上更新消息状态并保存
+func refreshMsgRecord(ctx context.Context, api v0api.FullNode, rec *msgRecord, pending map[cid.Cid]bool) error {
+	if err := lookupMsgStatus(ctx, api, rec, pending); err != nil {
+		return err
+	}
+	return saveMsgRecord(rec)
+}
+
+// lookupMsgStatus 从链上查询消息状态，pending 为节点消息池中的消息
+func lookupMsgStatus(ctx context.Context, api v0api.FullNode, rec *msgRecord, pending map[cid.Cid]bool) error {
+	c, err := cid.Decode(rec.Cid)
+	if err != nil {
+		return err
+	}
+
+	lookup, err := api.StateSearchMsg(ctx, c)
+	if err != nil {
+		return xerrors.Errorf("查询消息 %s 失败: %w", rec.Cid, err)
+	}
+
+	switch {
+	case lookup != nil && lookup.Message == c:
+		rec.Status = msgExecuted
+		if lookup.Receipt.ExitCode.IsError() {
+			rec.Status = msgFailed
+		}
+		rec.ExitCode = int64(lookup.Receipt.ExitCode)
+		rec.Height = int64(lookup.Height)
+	case lookup != nil:
+		// 同一个nonce的另一条消息上链了，例如在其他地方做了 mpool replace
+		rec.Status = msgReplaced
+		rec.ReplacedBy = lookup.Message.String()
+	case pending[c]:
+		rec.Status = msgPending
+	default:
+		from, err := address.NewFromString(rec.From)
+		if err != nil {
+			return err
+		}
+		act, err := api.StateGetActor(ctx, from, types.EmptyTSK)
+		if err != nil {
+			return err
+		}
+		if act.Nonce > rec.Nonce {
+			rec.Status = msgUnknown
+		} else {
+			rec.Status = msgDropped
+		}
+	}
+
+	return nil
+}
+
+// mpoolPendingCids 节点消息池中所有消息的cid
+func mpoolPendingCids(ctx context.Context, api v0api.FullNode) (map[cid.Cid]bool, error) {
+	mpending, err := api.MpoolPending(ctx, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+	pending := map[cid.Cid]bool{}
+	for _, m := range mpending {
+		pending[m.Cid()] = true
+	}
+	return pending, nil
+}
+
+var messagesCmd = &cli.Command{
+	Name:  "messages",
+	Usage: "查看本工具推送过的消息",
+	Subcommands: []*cli.Command{
+		messagesListCmd,
+		messagesShowCmd,
+		messagesRefreshCmd,
+	},
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+}
+
+var messagesListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "列出推送过的消息",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "只显示这个地址发送的消息",
+		},
+		&cli.StringFlag{
+			Name:  "miner",
+			Usage: "只显示这个矿工的owner地址发送的消息，需要连接节点",
+		},
+		&cli.StringFlag{
+			Name:  "status",
+			Usage: "只显示这个状态的消息: pending, executed, failed, replaced, dropped, unknown",
+		},
+		&cli.DurationFlag{
+			Name:  "since",
+			Usage: "只显示最近这段时间内的消息，例如 168h",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "最多显示的条数，0表示不限制",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		from := cctx.String("from")
+		if from != "" {
+			a, err := parseAddress(from)
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			from = a.String()
+		}
+
+		if cctx.IsSet("miner") {
+			api, closer, err := getFullNodeAPI(cctx)
+			if err != nil {
+				fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+				return err
+			}
+			defer closer()
+			ctx := lcli.ReqContext(cctx)
+
+			maddr, err := parseAddress(cctx.String("miner"))
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+			if err != nil {
+				fmt.Println("从链上读取矿工状态失败", err)
+				return err
+			}
+			owner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
+			if err != nil {
+				fmt.Printf("查询 %s 的公钥地址失败: %v\n", mi.Owner, err)
+				return err
+			}
+			from = owner.String()
+		}
+
+		recs, err := listMsgRecords()
+		if err != nil {
+			fmt.Printf("读取消息记录失败，err: %v\n", err)
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Time\tCid\tFrom\tNonce\tTo\tValue\tMethod\tCommand\tStatus\n")
+		n := 0
+		for _, rec := range recs {
+			if from != "" && rec.From != from {
+				continue
+			}
+			if cctx.IsSet("status") && rec.Status != cctx.String("status") {
+				continue
+			}
+			if cctx.IsSet("since") && time.Since(rec.Time) > cctx.Duration("since") {
+				continue
+			}
+			if cctx.Int("limit") > 0 && n >= cctx.Int("limit") {
+				break
+			}
+			n++
+
+			status := rec.Status
+			if rec.Status == msgExecuted || rec.Status == msgFailed {
+				status = fmt.Sprintf("%s(%d)", rec.Status, rec.ExitCode)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%d\t%s\t%s\n",
+				rec.Time.Format("2006-01-02 15:04:05"), rec.Cid, rec.From, rec.Nonce, rec.To, rec.Value, rec.Method, rec.Command, status)
+		}
+		return w.Flush()
+	},
+}
+
+var messagesShowCmd = &cli.Command{
+	Name:      "show",
+	Usage:     "显示消息记录的详情",
+	ArgsUsage: "<message cid>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定消息cid")
+			return fmt.Errorf("必须指定消息cid")
+		}
+
+		rec, err := getMsgRecord(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("没有找到消息 %s 的记录，err: %v\n", cctx.Args().First(), err)
+			return err
+		}
+
+		b, err := json.MarshalIndent(rec, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	},
+}
+
+var messagesRefreshCmd = &cli.Command{
+	Name:  "refresh",
+	Usage: "从链上更新消息状态，默认只更新pending的消息",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "更新所有消息",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		recs, err := listMsgRecords()
+		if err != nil {
+			fmt.Printf("读取消息记录失败，err: %v\n", err)
+			return err
+		}
+
+		pending, err := mpoolPendingCids(ctx, api)
+		if err != nil {
+			fmt.Printf("读取消息池失败，err: %v\n", err)
+			return err
+		}
+
+		for _, rec := range recs {
+			if !cctx.Bool("all") && rec.Status != msgPending {
+				continue
+			}
+
+			old := rec.Status
+			if err := refreshMsgRecord(ctx, api, rec, pending); err != nil {
+				fmt.Printf("更新消息 %s 失败: %v\n", rec.Cid, err)
+				continue
+			}
+			if old != rec.Status {
+				fmt.Printf("%s\t%s -> %s\n", rec.Cid, old, rec.Status)
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli/v2"
+)
+
+func TestRecordMessageReplace(t *testing.T) {
+	var err error
+	localdb, err = db.Init(t.TempDir())
+	assert.Nil(t, err)
+	defer func() { localdb = nil }()
+
+	cctx := cli.NewContext(&cli.App{}, nil, nil)
+	cctx.Command = &cli.Command{Name: "send"}
+
+	from, _ := address.NewIDAddress(1000)
+	to, _ := address.NewIDAddress(1001)
+	msg := types.Message{From: from, To: to, Value: types.FromFil(1), Nonce: 3, GasPremium: types.NewInt(100)}
+
+	orig := &types.SignedMessage{Message: msg}
+	recordMessage(cctx, orig, cid.Undef)
+
+	msg.GasPremium = types.NewInt(200)
+	repl := &types.SignedMessage{Message: msg}
+	recordMessage(cctx, repl, orig.Cid())
+
+	recs, err := listMsgRecords()
+	assert.Nil(t, err)
+	assert.Len(t, recs, 2)
+
+	old, err := getMsgRecord(orig.Cid().String())
+	assert.Nil(t, err)
+	assert.Equal(t, msgReplaced, old.Status)
+	assert.Equal(t, repl.Cid().String(), old.ReplacedBy)
+
+	rec, err := getMsgRecord(repl.Cid().String())
+	assert.Nil(t, err)
+	assert.Equal(t, msgPending, rec.Status)
+	assert.Equal(t, orig.Cid().String(), rec.Replaces)
+	assert.Equal(t, "send", rec.Command)
+}
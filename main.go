@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -10,10 +11,8 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
+	"sync"
 
-	crypto2 "github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/crypto/blake2b"
 	"github.com/filecoin-project/firefly-wallet/db"
 	"github.com/filecoin-project/firefly-wallet/impl"
 	"github.com/filecoin-project/firefly-wallet/mnemonic"
@@ -54,6 +53,8 @@ type FilAddressInfo struct {
 	AddrType string
 	Index    int
 	Address  string
+	Network  string
+	Tag      string `json:",omitempty"`
 }
 
 func getRepoPath() string {
@@ -93,100 +94,17 @@ func _initDb() error {
 }
 
 func signMessage(msg []byte, addr address.Address) (*crypto.Signature, error) {
-	faiByte, err := localdb.Get(db.KeyAddr, addr.String())
-	if err != nil {
-		fmt.Println("db中读取钱包地址", addr.String())
-		return &crypto.Signature{}, err
-		//panic(err)
+	if signers == nil {
+		fmt.Println("签名模块未初始化")
+		return &crypto.Signature{}, xerrors.Errorf("signers not loaded")
 	}
 
-	fai := FilAddressInfo{}
-	err = json.Unmarshal(faiByte, &fai)
+	sb, err := signers.Sign(context.TODO(), addr, msg)
 	if err != nil {
-		fmt.Printf("解析数据库信息是失败, err:%v\n", err)
+		fmt.Printf("签名失败,err: %v\n", err)
 		return &crypto.Signature{}, err
 	}
 
-	//mb, err := msg.ToStorageBlock()
-	//if err != nil {
-	//	fmt.Printf("序列化消息失败， err:%v", err)
-	//	return &crypto.Signature{}, xerrors.Errorf("serializing message: %w", err)
-	//}
-
-	// 导入钱包地址签名
-	if fai.Index == unRecoverIndex {
-		sb, err := unRecoverAddrSign(msg, addr)
-		if err != nil {
-			fmt.Printf("签名失败,err: %v\n", err)
-		}
-
-		return sb, nil
-	} else {
-		// 派生钱包地址签名
-		sb, err := impl.Sign(msg, addr, string(localMnenoic), fai.Index)
-		if err != nil {
-			fmt.Printf("签名失败,err: %v\n", err)
-		}
-
-		return sb, nil
-	}
-}
-
-// 非派生钱包地址，即不可恢复钱包地址，签名
-func unRecoverAddrSign(msg []byte, addr address.Address) (*crypto.Signature, error) {
-	encryptKey, err := localdb.Get(db.KeyPriKey, addr.String())
-	if err != nil {
-		fmt.Printf("从数据库读取钱包失败！,err: %v", err)
-		return &crypto.Signature{}, err
-	}
-
-	inpdata, err := mnemonic.Decrypt(encryptKey, passwd)
-	if err != nil {
-		fmt.Printf("读取私钥出错,err: %v", err)
-		return &crypto.Signature{}, err
-	}
-
-	var ki types.KeyInfo
-	data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
-	if err != nil {
-		fmt.Println("输入的私钥格式不正确，解析出错！")
-		return &crypto.Signature{}, err
-	}
-
-	if err := json.Unmarshal(data, &ki); err != nil {
-		fmt.Println("输入的私钥格式不正确，序列化出错！")
-		return &crypto.Signature{}, err
-	}
-
-	var sb *crypto.Signature
-	switch ki.Type {
-	case types.KTSecp256k1:
-		b2sum := blake2b.Sum256(msg)
-
-		priKey, err := crypto2.ToECDSA(ki.PrivateKey)
-		if err != nil {
-			return nil, err
-		}
-		sig, err := crypto2.Sign(b2sum[:], priKey)
-		if err != nil {
-			fmt.Printf("签名消息失败，err:%v", err)
-			return &crypto.Signature{}, err
-		}
-
-		sb = &crypto.Signature{
-			Type: crypto.SigTypeSecp256k1,
-			Data: sig,
-		}
-	case types.KTBLS:
-		sb, err = impl.SignBls(ki.PrivateKey[:], msg)
-		if err != nil {
-			fmt.Printf("签名消息失败，err:%v", err)
-			return &crypto.Signature{}, err
-		}
-	default:
-		return nil, xerrors.Errorf("unsupported key type: %s", ki.Type)
-	}
-
 	return sb, nil
 }
 
@@ -219,21 +137,13 @@ func _init() error {
 	if valid := impl.VerifyPassword(string(localMnenoic), 0); !valid {
 		return fmt.Errorf("密码错误！")
 	}
-	return nil
-}
 
-func initNetWork() {
-	if strings.Contains(os.Getenv("FULLNODE_API_INFO"), "calibration") {
-		address.CurrentNetwork = address.Testnet
-	}
-	if address.CurrentNetwork == address.Testnet {
-		fmt.Println("当前运行的是测试网")
-	} else if address.CurrentNetwork == address.Mainnet {
-		fmt.Println("当前运行的是主网")
-	} else {
-		fmt.Println("当前运行在未知网络，请检查：环境变量 FULLNODE_API_INFO 的配置! ")
-		os.Exit(1)
+	signers, err = loadSigners(context.TODO())
+	if err != nil {
+		fmt.Printf("加载签名模块失败，err: %v\n", err)
+		return err
 	}
+	return nil
 }
 
 func main() {
@@ -247,6 +157,17 @@ func main() {
 		listCmd,
 		importAddressCmd,
 		signCmd,
+		verifyCmd,
+		signBundleCmd,
+		broadcastCmd,
+		messagesCmd,
+		payoutsCmd,
+		treasuryCmd,
+		sweepCmd,
+		fundControlCmd,
+		beneficiaryCmd,
+		minerCmd,
+		nonceCmd,
 		setOwnerCmd,
 		proposeChangeWorker,
 		confirmChangeWorker,
@@ -263,12 +184,13 @@ func main() {
 		commCmd,
 		mpoolCmd,
 		newMinerCmd,
+		serveWalletAPICmd,
 	}
 
 	app := &cli.App{
 		Name:      "萤火虫钱包管理工具",
 		Usage:     "萤火虫钱包管理工具， 用于矿工提现，转账，签名，以及节点控制等功能",
-		UsageText: "通过环境变量 LOTUS_WALLET_TOOL_PATH 设置程序执行路径, 默认路径: ~/.lotuswallettool。 与链交互需要配置 FULLNODE_API_INFO 环境变量",
+		UsageText: "通过环境变量 LOTUS_WALLET_TOOL_PATH 设置程序执行路径, 默认路径: ~/.lotuswallettool。 与链交互需要配置 FULLNODE_API_INFO 环境变量。 使用远程钱包签名需要配置 REMOTE_WALLET_API_INFO 环境变量",
 		// Version:   build.UserVersion(),
 		Version: string(build.NodeUserVersion()),
 		//Flags: []cli.Flag{
@@ -277,10 +199,18 @@ func main() {
 		//		Value: "./data",
 		//	},
 		//},
+		Flags: []cli.Flag{
+			networkCliFlag,
+			dryRunCliFlag,
+		},
 		Commands: local,
 	}
 
-	if err := app.Run(os.Args); err != nil {
+	err := app.Run(os.Args)
+	if signerCloser != nil {
+		signerCloser()
+	}
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -311,72 +241,82 @@ var exportAddressCmd = &cli.Command{
 			return nil
 		}
 
-		faiByte, err := localdb.Get(db.KeyAddr, address)
+		privKey, err := exportPrivateKey(address)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(privKey)
+		return nil
+	},
+}
+
+// exportPrivateKey 导出本地钱包地址的私钥，格式为hex-lotus
+func exportPrivateKey(address string) (string, error) {
+	faiByte, err := localdb.Get(db.KeyAddr, address)
+	if err != nil {
+		fmt.Printf("从数据库读取钱包失败！,err: %v", err)
+		return "", err
+	}
+
+	fai := FilAddressInfo{}
+	err = json.Unmarshal(faiByte, &fai)
+	if err != nil {
+		fmt.Printf("反序列化数据(%v)失败！,err: %v", faiByte, err)
+		return "", err
+	}
+
+	var privKey string
+	if fai.Index == unRecoverIndex {
+		encryptKey, err := localdb.Get(db.KeyPriKey, address)
 		if err != nil {
 			fmt.Printf("从数据库读取钱包失败！,err: %v", err)
-			return nil
+			return "", err
 		}
 
-		fai := FilAddressInfo{}
-		err = json.Unmarshal(faiByte, &fai)
+		inpdata, err := mnemonic.Decrypt(encryptKey, passwd)
 		if err != nil {
-			fmt.Printf("反序列化数据(%v)失败！,err: %v", faiByte, err)
-			return nil
+			fmt.Printf("读取私钥出错,err: %v", err)
+			return "", err
 		}
 
-		var privKey string
-		if fai.Index == unRecoverIndex {
-			encryptKey, err := localdb.Get(db.KeyPriKey, address)
-			if err != nil {
-				fmt.Printf("从数据库读取钱包失败！,err: %v", err)
-				return nil
-			}
+		var ki types.KeyInfo
+		data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
+		if err != nil {
+			fmt.Println("输入的私钥格式不正确，解析出错！")
+			return "", err
+		}
 
-			inpdata, err := mnemonic.Decrypt(encryptKey, passwd)
-			if err != nil {
-				fmt.Printf("读取私钥出错,err: %v", err)
-				return nil
-			}
+		if err := json.Unmarshal(data, &ki); err != nil {
+			fmt.Println("输入的私钥格式不正确，序列化出错！")
+			return "", err
+		}
 
-			var ki types.KeyInfo
-			data, err := hex.DecodeString(strings.TrimSpace(string(inpdata)))
-			if err != nil {
-				fmt.Println("输入的私钥格式不正确，解析出错！")
-				return err
-			}
+		b, err := json.Marshal(ki)
+		if err != nil {
+			fmt.Println("序列化私钥出错，原因:", err.Error())
+			return "", err
+		}
 
-			if err := json.Unmarshal(data, &ki); err != nil {
-				fmt.Println("输入的私钥格式不正确，序列化出错！")
-				return err
-			}
+		privKey = hex.EncodeToString(b)
 
-			b, err := json.Marshal(ki)
+	} else {
+		if strings.HasPrefix(fai.Address, "f3") || strings.HasPrefix(fai.Address, "t3") {
+			privKey, err = impl.ExportBlsAddress(string(localMnenoic), fai.Index)
 			if err != nil {
-				fmt.Println("序列化私钥出错，原因:", err.Error())
-				return err
+				fmt.Printf("导出BLS钱包失败！,err: %v", err)
+				return "", err
 			}
-
-			privKey = hex.EncodeToString(b)
-
 		} else {
-			if strings.HasPrefix(fai.Address, "f3") || strings.HasPrefix(fai.Address, "t3") {
-				privKey, err = impl.ExportBlsAddress(string(localMnenoic), fai.Index)
-				if err != nil {
-					fmt.Printf("导出BLS钱包失败！,err: %v", err)
-					return nil
-				}
-			} else {
-				privKey, err = impl.ExportSecp256k1Address(string(localMnenoic), fai.Index)
-				if err != nil {
-					fmt.Printf("导出Secp256钱包失败！,err: %v", err)
-					return nil
-				}
+			privKey, err = impl.ExportSecp256k1Address(string(localMnenoic), fai.Index)
+			if err != nil {
+				fmt.Printf("导出Secp256钱包失败！,err: %v", err)
+				return "", err
 			}
 		}
+	}
 
-		fmt.Println(privKey)
-		return nil
-	},
+	return privKey, nil
 }
 
 var sendCmd = &cli.Command{
@@ -395,13 +335,17 @@ var sendCmd = &cli.Command{
 			Name:  "amount",
 			Usage: "转账金额",
 		},
+		unsignedOutFlag,
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
@@ -414,7 +358,7 @@ var sendCmd = &cli.Command{
 			return nil
 		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
@@ -424,13 +368,13 @@ var sendCmd = &cli.Command{
 		ctx := lcli.ReqContext(cctx)
 		msg := &types.Message{}
 
-		msg.From, err = address.NewFromString(cctx.String("from"))
+		msg.From, err = parseAddress(cctx.String("from"))
 		if err != nil {
 			fmt.Printf("解析转账源地址失败: %v", err)
 			return fmt.Errorf("failed to parse source address: %w\n", err)
 		}
 
-		msg.To, err = address.NewFromString(cctx.String("to"))
+		msg.To, err = parseAddress(cctx.String("to"))
 		if err != nil {
 			fmt.Printf("解析转账目标地址失败: %v", err)
 			return fmt.Errorf("failed to parse target address: %w\n", err)
@@ -452,45 +396,15 @@ var sendCmd = &cli.Command{
 
 		msg.Method = 0
 
-		// 获取nonce
-		msg.Nonce, err = api.MpoolGetNonce(ctx, msg.From)
+		msgCid, err := sendMessage(ctx, cctx, api, msg, false)
 		if err != nil {
-			fmt.Printf("读取获取消息池中的的nonce失败，err:%v\n", err)
-			a, err := api.StateGetActor(ctx, msg.From, types.EmptyTSK)
-			if err != nil {
-				fmt.Printf("读取获取链数据上的的nonce失败，err:%v\n", err)
-				return err
-			}
-			msg.Nonce = a.Nonce
-		}
-
-		msg, err = api.GasEstimateMessageGas(ctx, msg, nil, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-			return xerrors.Errorf("GasEstimateMessageGas error: %w", err)
-		}
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		if msgCid == cid.Undef {
+			return nil
+		}
 
-		fmt.Printf("转账消息id： %s\n", cid.String())
+		fmt.Printf("转账消息id： %s\n", msgCid.String())
 
 		return nil
 	},
@@ -561,6 +475,18 @@ var initCmd = &cli.Command{
 			return err
 		}
 
+		// 保存网络配置，之后不需要再指定 --network。保留配置文件中的其他设置
+		cfg, err := loadRepoConfig()
+		if err != nil {
+			fmt.Printf("读取配置文件失败！err: %v\n", err)
+			return err
+		}
+		cfg.Network = activeNetwork
+		if err := saveRepoConfig(cfg); err != nil {
+			fmt.Printf("保存网络配置失败！err: %v\n", err)
+			return err
+		}
+
 		encryptText, err := localdb.Get(db.KeyCommon, encryptKey)
 		if err != nil {
 			fmt.Printf("读取化DB失败，err: %v\n", err)
@@ -574,7 +500,10 @@ var initCmd = &cli.Command{
 		}
 
 		// 初始化创建一个钱包地址,用于后续验证密码使用
-		createAddress(false, false, "", "")
+		if _, _, err := createAddress(false, false, "", ""); err != nil {
+			fmt.Printf("创建钱包地址失败，err: %v\n", err)
+			return err
+		}
 		return nil
 	},
 }
@@ -634,7 +563,10 @@ var newAddressCmd = &cli.Command{
 		}
 
 		showPK := context.Bool("show-private-key")
-		createAddress(showPK, context.Bool("bls"), miner, addrType)
+		if _, _, err := createAddress(showPK, context.Bool("bls"), miner, addrType); err != nil {
+			fmt.Printf("创建钱包地址失败，err: %v\n", err)
+			return err
+		}
 		return nil
 	},
 }
@@ -726,7 +658,7 @@ var importAddressCmd = &cli.Command{
 			return fmt.Errorf("unrecognized format: %s", cctx.String("format"))
 		}
 
-		//api, closer, err := lcli.GetFullNodeAPI(cctx)
+		//api, closer, err := getFullNodeAPI(cctx)
 		//if err != nil {
 		//	fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 		//	return err
@@ -741,7 +673,7 @@ var importAddressCmd = &cli.Command{
 			return err
 		}
 
-		filInfo := FilAddressInfo{Address: key.Address.String(), Index: unRecoverIndex, AddrType: string(key.Type)}
+		filInfo := FilAddressInfo{Address: key.Address.String(), Index: unRecoverIndex, AddrType: string(key.Type), Network: activeNetwork}
 		filInfoByte, err := json.Marshal(&filInfo)
 		if err != nil {
 			fmt.Println("序列化filInfo失败!")
@@ -788,7 +720,7 @@ var walletBalance = &cli.Command{
 		//	fmt.Println("密码错误.")
 		//	return fmt.Errorf("密码错误")
 		//}
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
@@ -798,7 +730,7 @@ var walletBalance = &cli.Command{
 
 		var addr address.Address
 		if cctx.Args().First() != "" {
-			addr, err = address.NewFromString(cctx.Args().First())
+			addr, err = parseAddress(cctx.Args().First())
 		} else {
 			addr, err = api.WalletDefaultAddress(ctx)
 		}
@@ -823,284 +755,6 @@ var walletBalance = &cli.Command{
 	},
 }
 
-// 主网
-//var outAddrStr = "f1dnyrev6qjndjioanm3gmzkgavs2u5xxzvs5uwhq"
-//var inAddrStr = "f13anz7poviwzw765bvjyngvtnys3xm5pbhqe4jna"
-
-// 出账账户
-//var outAddrStr = "f12ijdsnibjsvxhticyy3ybk64j3nde6beoc4gawq"
-//
-//// 总账户
-//var inAddrStr = "f1y2y6srzoihm6dr4fahh27exrctc52l6r4xmuwqy"
-
-// 出账账户
-var outAddrStr = "f1jfx7m2qigjdf7urjapadrzbkakkpn53tobp34hq"
-
-// 总账户
-var inAddrStr = "f1tqj3wdoxlrspmoyqdkaokq25wleilq347enpbtq"
-
-var walletSendBatchCmd = &cli.Command{
-	Name:      "send-batch",
-	Usage:     "send batch amount file",
-	ArgsUsage: "[batch-file]",
-	Flags: []cli.Flag{
-		&cli.StringFlag{
-			Name:   "from",
-			Usage:  "from",
-			Hidden: true,
-		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
-	Action: func(cctx *cli.Context) error {
-		//from := cctx.String("from")
-		//if from == "" {
-		//	from = outAddrStr
-		//	fmt.Printf("from address is empty, use default outAddr(%s)\n", outAddrStr)
-		//}
-
-		if !passwdValid {
-			fmt.Println("密码错误.")
-			return fmt.Errorf("密码错误")
-		}
-
-		outAddr, err := address.NewFromString(outAddrStr)
-		if err != nil {
-			fmt.Println("outAddrStr invalid. ", outAddrStr)
-			return err
-		}
-
-		inAddr, err := address.NewFromString(inAddrStr)
-		if err != nil {
-			fmt.Println("inAddrStr address invalid.  ", inAddrStr)
-			return err
-		}
-
-		fromAddr := outAddr
-
-		batchSendFile := cctx.Args().First()
-		fmt.Println(batchSendFile)
-		if !strings.Contains(batchSendFile, "withdraw.list") {
-			fmt.Println("invalid batch file")
-			return nil
-		}
-
-		batchSendBytes, err := os.ReadFile(batchSendFile)
-		if err != nil {
-			fmt.Printf("open and read file(%s) failed. err:%v\n", "./t", err)
-			return err
-		}
-
-		//fmt.Printf("%v\n",[]byte("\n"))
-		type Trans struct {
-			To     string
-			Amount string
-		}
-
-		var willTrans []Trans
-		var totalAmount float64
-		items := bytes.Split(batchSendBytes, []byte{10})
-		for count, item := range items {
-			//fmt.Println(item)
-			subItem := bytes.Split(item, []byte{9})
-			if len(subItem) == 2 {
-				trans := Trans{To: string(subItem[1]), Amount: string(subItem[0])}
-				willTrans = append(willTrans, trans)
-				fmt.Printf("%d ./firefly-wallet send --from %s --to %s --amount %s\n", count+1, fromAddr.String(), trans.To, trans.Amount)
-
-				amount, err := strconv.ParseFloat(trans.Amount, 64)
-				if err != nil {
-					fmt.Println("parse ", trans.Amount, " failed.")
-					return nil
-				}
-				totalAmount += amount
-			}
-		}
-
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
-		if err != nil {
-			return err
-		}
-		defer closer()
-		ctx := lcli.ReqContext(cctx)
-
-		// 查询outAddr余额是否足够支付totalAmount+手续费，够则直接支付
-		// 不够，则查询inAddr余额是否足够支付totalAmount，如果够，则算出手续费，+totalAmount并转帐到outAddr中去，然后下发
-		// 不够，则退出提示归集资金
-		outAddrBalance, err := api.WalletBalance(ctx, outAddr)
-		if err != nil {
-			fmt.Println(outAddr, " get balance failed. err: ", err)
-			return err
-		}
-
-		fmt.Printf("\n出账账户 %s 余额： %s\n", outAddrStr, types.FIL(outAddrBalance).Short())
-		fmt.Printf("总共转账 %d 笔， 共计： %f FIL\n\n", len(willTrans), totalAmount)
-
-		// 转账手续费,按照每笔0.01fil计算
-		transFee := float64(len(willTrans)+1) * 0.01
-		outBalanceFloat, err := strconv.ParseFloat(types.FIL(outAddrBalance).Unitless(), 64)
-		if err != nil {
-			fmt.Println(outAddrBalance, " parse float. err: ", err)
-			return err
-		}
-
-		if outBalanceFloat-transFee < totalAmount {
-			// outAddr余额不足
-
-			inAddrBalance, err := api.WalletBalance(ctx, inAddr)
-			if err != nil {
-				fmt.Println(inAddr, " get balance failed. err: ", err)
-				return err
-			}
-
-			inBalanceFloat, err := strconv.ParseFloat(types.FIL(inAddrBalance).Unitless(), 64)
-			if err != nil {
-				fmt.Println(inAddrBalance, " parse float. err: ", err)
-				return err
-			}
-
-			fmt.Printf("总账户 %s 余额： %f\n", inAddrStr, inBalanceFloat)
-			fmt.Printf("出账账户 %s 余额： %f\n", outAddrStr, outBalanceFloat)
-
-			if inBalanceFloat+outBalanceFloat-transFee < totalAmount {
-				// 总账户+出账账户+需要手续费不足以提现，退出
-				fmt.Printf("inAddr(%f) + outAddr(%f) - transFee(%f) = %f 小于提现金额 %f。 请归集资金再来！\n", inBalanceFloat, outBalanceFloat, transFee, inBalanceFloat+outBalanceFloat-transFee, totalAmount)
-				return nil
-			}
-
-			// 总账户+出账账户足以支持提现，那么从总账户转入差额，再提现
-			fmt.Printf("提现总金额（提现金额+手续费）: %f ，从 总账户 转入 %f 到出账账户。\n", totalAmount+transFee, totalAmount+transFee-outBalanceFloat+0.02)
-			fmt.Printf("./firefly-wallet send --from %s %s %f\n", inAddr, outAddr, totalAmount+transFee-outBalanceFloat+0.02)
-
-			var sure string
-			fmt.Printf("请核对转账信息：Y/n : ")
-			_, err = fmt.Scanf("%s", &sure)
-			if err != nil {
-				fmt.Println("获取终端输入异常，err: ", err)
-				return nil
-			}
-			if sure != "Y" {
-				fmt.Println("取消转账")
-				return nil
-			}
-
-			var resure string
-			fmt.Printf("请核对转账信息：yes/n : ")
-			_, err = fmt.Scanf("%s", &resure)
-			if err != nil {
-				fmt.Println("获取终端输入异常，err: ", err)
-				return nil
-			}
-			if resure != "yes" {
-				fmt.Println("取消转账")
-				return nil
-			}
-
-			cid, err := send(cctx, api, inAddr, outAddr.String(), fmt.Sprintf("%f", totalAmount+transFee-outBalanceFloat+0.02), 0)
-			if err != nil {
-				fmt.Println("transfor err: \n", err)
-				return nil
-			}
-			fmt.Println(cid.String())
-			ticker := time.NewTicker(time.Minute * 3)
-			canTransFil := false
-			for {
-				select {
-				case <-time.After(time.Second * 5):
-					outAddrBalanceTmp, err := api.WalletBalance(ctx, outAddr)
-					if err != nil {
-						fmt.Println(outAddr, " get balance failed. err: ", err)
-						return err
-					}
-					outBalanceFloatTmp, err := strconv.ParseFloat(types.FIL(outAddrBalanceTmp).Unitless(), 64)
-					if err != nil {
-						fmt.Println(outAddrBalance, " parse float. err: ", err)
-						return err
-					}
-					if outBalanceFloatTmp-transFee >= totalAmount {
-						fmt.Printf("\n出账账户 %s 余额： %s 足以支付提现金额: %f \n", outAddrStr, types.FIL(outAddrBalanceTmp).Short(), totalAmount)
-						canTransFil = true
-						break
-					}
-
-				case <-ticker.C:
-					fmt.Printf("从总账户(%s) 转入 %f 到出账账户（%s） 超过2min还未到账，程序退出请手动确认转账是否成功  \n", inAddrStr, totalAmount+transFee-outBalanceFloat+0.02, outAddrStr)
-					return nil
-				}
-
-				if canTransFil {
-					break
-				}
-			}
-		}
-
-		var sure string
-		fmt.Printf("请核对转账信息：Y/n : ")
-		_, err = fmt.Scanf("%s", &sure)
-		if err != nil {
-			fmt.Println("获取终端输入异常，err: ", err)
-			return nil
-		}
-		if sure != "Y" {
-			fmt.Println("取消转账")
-			return nil
-		}
-
-		var resure string
-		fmt.Printf("请核对转账信息：yes/n : ")
-		_, err = fmt.Scanf("%s", &resure)
-		if err != nil {
-			fmt.Println("获取终端输入异常，err: ", err)
-			return nil
-		}
-		if resure != "yes" {
-			fmt.Println("取消转账")
-			return nil
-		}
-
-		a, err := api.StateGetActor(ctx, fromAddr, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取msg.From地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
-		//删除提现文件数据
-		defer os.Remove(batchSendFile)
-		var successCount int
-		var successAmount float64
-		nonce := a.Nonce
-		for count, trans := range willTrans {
-			amount, err := strconv.ParseFloat(trans.Amount, 64)
-			if err != nil {
-				fmt.Println("parse ", trans.Amount, " failed.")
-				break
-			}
-			fmt.Printf("%d ./firefly-wallet send --from %s %s %s", count+1, fromAddr.String(), trans.To, trans.Amount)
-
-			cid, err := send(cctx, api, fromAddr, trans.To, trans.Amount, nonce)
-			if err != nil {
-				if strings.Compare("转入和转出地址相同", err.Error()) == 0 {
-					continue
-				}
-
-				fmt.Println("transfor err: \n", err)
-				break
-			}
-			fmt.Println(cid.String())
-			nonce++
-			successCount++
-			successAmount += amount
-		}
-
-		fmt.Printf("计划转 %d 笔， 共计 %f FIL\n实际完成 %d 笔，共计 %f FIL, 失败 %d 笔\n", len(willTrans), totalAmount, successCount, successAmount, len(willTrans)-successCount)
-		return nil
-	},
-}
-
 var listCmd = &cli.Command{
 	Name:  "list",
 	Usage: "展示钱包列表",
@@ -1132,7 +786,7 @@ var listCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
@@ -1162,10 +816,22 @@ var listCmd = &cli.Command{
 
 		for _, a := range addrs {
 			fa := FilAddressInfo{}
-			json.Unmarshal([]byte(a), &fa)
-			addr, err := address.NewFromString(fa.Address)
+			if err := json.Unmarshal([]byte(a), &fa); err != nil {
+				// usdt地址也保存在KeyAddr下，不是json
+				continue
+			}
+			addr, err := parseAddress(fa.Address)
 			if err != nil {
-				return err
+				// 其他网络创建的地址不影响列出当前网络的地址
+				if cctx.Bool("addr-only") {
+					fmt.Fprintf(os.Stderr, "跳过地址 %s: %v\n", fa.Address, err)
+				} else {
+					tw.Write(map[string]interface{}{
+						"Address": fa.Address,
+						"Error":   err,
+					})
+				}
+				continue
 			}
 
 			if cctx.Bool("addr-only") {
@@ -1251,7 +917,7 @@ var signCmd = &cli.Command{
 			return fmt.Errorf("必须指定签名钱包地址和要签名的消息")
 		}
 
-		addr, err := address.NewFromString(cctx.Args().First())
+		addr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println("输入签名的钱包地址异常,", err)
 			return err
@@ -1299,6 +965,10 @@ var batchGenerateKeyCmd = &cli.Command{
 			Usage: "指定这个命令则写私钥到./tmpfile.txt文件中，否则不写入",
 			Value: false,
 		},
+		&cli.StringFlag{
+			Name:  "tag",
+			Usage: "给创建的地址设置标签，可以在 sweep --tag 时按标签筛选",
+		},
 	},
 	Before: func(context *cli.Context) error {
 		if err := _init(); err != nil {
@@ -1336,7 +1006,17 @@ var batchGenerateKeyCmd = &cli.Command{
 
 		for i := 0; i < num; i++ {
 			fmt.Printf("%d ------------>>>>>>>>>>>>\n", i)
-			priKey := createAddress(showPK, context.Bool("bls"), "", "")
+			addr, priKey, err := createAddress(showPK, context.Bool("bls"), "", "")
+			if err != nil {
+				fmt.Printf("创建钱包地址失败，err: %v\n", err)
+				return err
+			}
+			if tag := context.String("tag"); tag != "" {
+				if err := setAddressTag(addr, tag); err != nil {
+					fmt.Println(err.Error())
+					return err
+				}
+			}
 
 			if context.Bool("save-private-key") {
 				_, err = f.WriteString(fmt.Sprintf("%s\n", priKey))
@@ -1350,41 +1030,63 @@ var batchGenerateKeyCmd = &cli.Command{
 	},
 }
 
-func createAddress(show, bls bool, miner, addrType string) string {
-	priKey := ""
+// setAddressTag 设置本地钱包地址的标签
+func setAddressTag(addr, tag string) error {
+	b, err := localdb.Get(db.KeyAddr, addr)
+	if err != nil {
+		return xerrors.Errorf("本地数据库没有查询到key（%v）: %w", addr, err)
+	}
+
+	var fai FilAddressInfo
+	if err := json.Unmarshal(b, &fai); err != nil {
+		return xerrors.Errorf("解析数据(%s)失败: %w", b, err)
+	}
+	fai.Tag = tag
+
+	b, err = json.Marshal(&fai)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeyAddr, addr, b)
+}
+
+// addressLk 保证并发创建地址(如钱包api的WalletNew)时不会拿到相同的index
+var addressLk sync.Mutex
+
+// createAddress 创建钱包地址，返回钱包地址和私钥
+func createAddress(show, bls bool, miner, addrType string) (string, string, error) {
+	addressLk.Lock()
+	defer addressLk.Unlock()
+
 	if bls {
 		// t3...
-		priKey = generateBlsFilAddress(show, miner, addrType)
-	} else {
-		// t1....
-		priKey = generateFilAddress(show, miner, addrType)
+		return generateBlsFilAddress(show, miner, addrType)
 	}
-	return priKey
+	// t1....
+	return generateFilAddress(show, miner, addrType)
 }
 
-func getNextIndex() int {
+func getNextIndex() (int, error) {
 
 	strIndex, err := localdb.Get(db.KeyIndex, NEXT)
 	if err != nil {
 		if err != errors.ErrNotFound {
-			panic(err)
+			return 0, err
 		}
 		strIndex = []byte("0")
 	}
 
-	index, err := strconv.Atoi(string(strIndex))
-	if err != nil {
-		panic(err)
-	}
-
-	return index
+	return strconv.Atoi(string(strIndex))
 }
 
-func generateBlsFilAddress(showPK bool, miner, addrType string) string {
-	index := getNextIndex()
+func generateBlsFilAddress(showPK bool, miner, addrType string) (string, string, error) {
+	index, err := getNextIndex()
+	if err != nil {
+		return "", "", xerrors.Errorf("读取地址index失败: %w", err)
+	}
 	filAddr, err := impl.CreateBlsFilAddress(string(localMnenoic), index)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	fai := FilAddressInfo{
@@ -1392,13 +1094,14 @@ func generateBlsFilAddress(showPK bool, miner, addrType string) string {
 		Index:    index,
 		MinerId:  miner,
 		AddrType: addrType,
+		Network:  activeNetwork,
 	}
 
 	fmt.Println(fai)
 
 	priKey, err := impl.ExportBlsAddress(string(localMnenoic), index)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	if showPK {
@@ -1408,27 +1111,32 @@ func generateBlsFilAddress(showPK bool, miner, addrType string) string {
 	faiByte, err := json.Marshal(&fai)
 	//fmt.Println(filAddr)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	err = localdb.Add(db.KeyAddr, filAddr, faiByte)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	err = localdb.Add(db.KeyIndex, NEXT, []byte(fmt.Sprintf("%d", index+1)))
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
-	return priKey
+
+	registerHDAddress(filAddr, index)
+	return filAddr, priKey, nil
 }
 
-func generateFilAddress(showPK bool, miner, addrType string) string {
+func generateFilAddress(showPK bool, miner, addrType string) (string, string, error) {
 	mnenoic := string(localMnenoic)
-	index := getNextIndex()
+	index, err := getNextIndex()
+	if err != nil {
+		return "", "", xerrors.Errorf("读取地址index失败: %w", err)
+	}
 	filAddr, err := impl.CreateSecp256k1FilAddress(mnenoic, index)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	fai := FilAddressInfo{
@@ -1436,6 +1144,7 @@ func generateFilAddress(showPK bool, miner, addrType string) string {
 		Index:    index,
 		MinerId:  miner,
 		AddrType: addrType,
+		Network:  activeNetwork,
 	}
 
 	//fmt.Println(filAddr)
@@ -1443,7 +1152,7 @@ func generateFilAddress(showPK bool, miner, addrType string) string {
 
 	priKey, err := impl.ExportSecp256k1Address(mnenoic, index)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	if showPK {
@@ -1452,20 +1161,21 @@ func generateFilAddress(showPK bool, miner, addrType string) string {
 
 	faiByte, err := json.Marshal(&fai)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	err = localdb.Add(db.KeyAddr, filAddr, faiByte)
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
 	err = localdb.Add(db.KeyIndex, NEXT, []byte(fmt.Sprintf("%d", index+1)))
 	if err != nil {
-		panic(err)
+		return "", "", err
 	}
 
-	return priKey
+	registerHDAddress(filAddr, index)
+	return filAddr, priKey, nil
 }
 
 func getPassword() ([]byte, error) {
@@ -1521,11 +1231,12 @@ func keyExist(localdb *db.LocalDb) bool {
 	return true
 }
 
-func send(cctx *cli.Context, api v0api.FullNode, from address.Address, to, amount string, nonce uint64) (cid.Cid, error) {
+// send 转账，beforePush 不为空时在推送前调用，可以在推送前记录签名后的消息
+func send(cctx *cli.Context, api v0api.FullNode, from address.Address, to, amount string, beforePush func(*types.SignedMessage) error) (cid.Cid, error) {
 	ctx := lcli.ReqContext(cctx)
 	msg := &types.Message{}
 	var err error
-	msg.To, err = address.NewFromString(to)
+	msg.To, err = parseAddress(to)
 	if err != nil {
 		return cid.Cid{}, fmt.Errorf("failed to parse target address: %w", err)
 	}
@@ -1542,37 +1253,49 @@ func send(cctx *cli.Context, api v0api.FullNode, from address.Address, to, amoun
 		return cid.Cid{}, fmt.Errorf("转入和转出地址相同")
 	}
 
-	if nonce != 0 {
-		msg.Nonce = nonce
-	} else {
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, msg.From, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取msg.From地址的nonce失败，err:%v\n", err)
-			return cid.Cid{}, err
-		}
-		msg.Nonce = a.Nonce
+	// 获取nonce
+	nonce, err := reserveNonce(ctx, api, msg.From)
+	if err != nil {
+		fmt.Printf("读取获取msg.From地址的nonce失败，err:%v\n", err)
+		return cid.Cid{}, err
 	}
+	msg.Nonce = nonce
 
 	//msgCid, err := srv.Send(ctx, params)
-	msg, err = api.GasEstimateMessageGas(ctx, msg, nil, types.EmptyTSK)
+	msg, err = estimateMessageGas(ctx, cctx, api, msg)
 	if err != nil {
+		releaseNonce(from, nonce)
 		fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-		return cid.Cid{}, xerrors.Errorf("GasEstimateMessageGas error: %w", err)
+		return cid.Cid{}, err
+	}
+
+	if dryRun {
+		releaseNonce(from, nonce)
+		return cid.Undef, simulateMessage(ctx, api, msg)
 	}
 
 	// 签名
 	signMsg, err := signMessage(msg.Cid().Bytes(), msg.From)
 	if err != nil {
+		releaseNonce(from, nonce)
 		return cid.Cid{}, err
 	}
 
 	// 推送消息
 
-	ccid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *signMsg})
+	smsg := &types.SignedMessage{Message: *msg, Signature: *signMsg}
+	if beforePush != nil {
+		if err := beforePush(smsg); err != nil {
+			releaseNonce(from, nonce)
+			return cid.Cid{}, err
+		}
+	}
+	ccid, err := api.MpoolPush(ctx, smsg)
 	if err != nil {
+		releaseNonce(from, nonce)
 		fmt.Printf("推送消息上链失败，err:%v\n", err)
 		return cid.Cid{}, err
 	}
+	recordMessage(cctx, smsg, cid.Undef)
 	return ccid, nil
 }
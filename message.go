@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/consensus"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 离线签名文件的格式版本
+const bundleVersion = 1
+
+var unsignedOutFlag = &cli.StringFlag{
+	Name:  "unsigned-out",
+	Usage: "只构造消息不签名，把估算好gas的消息写入指定文件，在离线机器上用 sign-bundle 签名后再用 broadcast 推送",
+}
+
+// messageBefore 发送消息的命令使用，只输出未签名消息或 --dry-run 时不需要输入密码
+func messageBefore(cctx *cli.Context) error {
+	if cctx.IsSet(unsignedOutFlag.Name) || dryRun {
+		if err := _initDb(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	}
+
+	if err := _init(); err != nil {
+		passwdValid = false
+	}
+	return nil
+}
+
+// messageBundle 离线签名的消息文件，构造、签名、推送分别在不同的机器上完成
+type messageBundle struct {
+	Version     int
+	Network     string
+	NetworkName string
+	CreatedAt   time.Time
+	Summary     []string
+	Message     *types.Message
+	Signature   *crypto.Signature `json:",omitempty"`
+}
+
+// sendMessage 消息发送的公共流程：获取nonce、评估gas、签名、推送。
+// 指定 --unsigned-out 时只把未签名的消息写入文件，指定 --dry-run 时只模拟执行，都返回 cid.Undef
+func sendMessage(ctx context.Context, cctx *cli.Context, api v0api.FullNode, msg *types.Message, validNonce bool) (cid.Cid, error) {
+	// 获取nonce，推送失败时归还自动分配的nonce
+	release := func() {}
+	if !validNonce {
+		nonce, err := reserveNonce(ctx, api, msg.From)
+		if err != nil {
+			fmt.Printf("读取获取%s地址的nonce失败，err:%v\n", msg.From, err)
+			return cid.Undef, err
+		}
+		msg.Nonce = nonce
+		from := msg.From
+		release = func() { releaseNonce(from, nonce) }
+	}
+
+	emsg, err := estimateMessageGas(ctx, cctx, api, msg)
+	if err != nil {
+		release()
+		fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
+		if dryRun {
+			// 评估失败一般是消息执行失败，模拟执行一次显示失败原因
+			printMessage(ctx, api, msg)
+			return cid.Undef, simulateMessage(ctx, api, msg)
+		}
+		return cid.Undef, err
+	}
+	msg = emsg
+
+	printMessage(ctx, api, msg)
+
+	if dryRun {
+		release()
+		return cid.Undef, simulateMessage(ctx, api, msg)
+	}
+
+	if out := cctx.String(unsignedOutFlag.Name); out != "" {
+		bundle, err := newMessageBundle(ctx, api, msg)
+		if err != nil {
+			fmt.Printf("构造未签名消息失败，err:%v\n", err)
+			return cid.Undef, err
+		}
+		if err := writeMessageBundle(out, bundle); err != nil {
+			fmt.Printf("写入未签名消息(%s)失败，err:%v\n", out, err)
+			return cid.Undef, err
+		}
+		fmt.Printf("未签名消息已写入 %s，请在离线机器上执行 sign-bundle 签名\n", out)
+		return cid.Undef, nil
+	}
+
+	if err := confirmSend(cctx); err != nil {
+		release()
+		if err == errCanceled {
+			return cid.Undef, nil
+		}
+		return cid.Undef, err
+	}
+
+	// 签名
+	sb, err := signMessage(msg.Cid().Bytes(), msg.From)
+	if err != nil {
+		release()
+		fmt.Printf("签名失败， err:%v\n", err)
+		return cid.Undef, xerrors.Errorf("签名失败: %w", err)
+	}
+
+	// 推送消息
+	smsg := &types.SignedMessage{Message: *msg, Signature: *sb}
+	msgCid, err := api.MpoolPush(ctx, smsg)
+	if err != nil {
+		release()
+		fmt.Printf("推送消息上链失败，err:%v\n", err)
+		return cid.Undef, err
+	}
+	recordMessage(cctx, smsg, cid.Undef)
+
+	fmt.Println("Message CID:", msgCid.String())
+
+	if cctx.Bool(waitFlag.Name) {
+		if _, err := waitMessage(ctx, cctx, api, msgCid); err != nil {
+			return msgCid, err
+		}
+	}
+	return msgCid, nil
+}
+
+// pushMessage 批量命令使用的发送流程：获取nonce、评估gas、签名、推送，不打印消息也不确认，调用方需要先统一确认
+func pushMessage(ctx context.Context, cctx *cli.Context, api v0api.FullNode, msg *types.Message) (cid.Cid, error) {
+	nonce, err := reserveNonce(ctx, api, msg.From)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("读取%s的nonce失败: %w", msg.From, err)
+	}
+	from := msg.From
+	msg.Nonce = nonce
+
+	emsg, err := estimateMessageGas(ctx, cctx, api, msg)
+	if err != nil {
+		releaseNonce(from, nonce)
+		return cid.Undef, xerrors.Errorf("评估消息的gas费用失败: %w", err)
+	}
+
+	sb, err := signMessage(emsg.Cid().Bytes(), from)
+	if err != nil {
+		releaseNonce(from, nonce)
+		return cid.Undef, xerrors.Errorf("签名失败: %w", err)
+	}
+
+	smsg := &types.SignedMessage{Message: *emsg, Signature: *sb}
+	msgCid, err := api.MpoolPush(ctx, smsg)
+	if err != nil {
+		releaseNonce(from, nonce)
+		return cid.Undef, xerrors.Errorf("推送消息上链失败: %w", err)
+	}
+	recordMessage(cctx, smsg, cid.Undef)
+	return msgCid, nil
+}
+
+func newMessageBundle(ctx context.Context, api v0api.FullNode, msg *types.Message) (*messageBundle, error) {
+	name, err := api.StateNetworkName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &messageBundle{
+		Version:     bundleVersion,
+		Network:     activeNetwork,
+		NetworkName: string(name),
+		CreatedAt:   time.Now(),
+		Summary:     summarizeMessage(ctx, api, msg),
+		Message:     msg,
+	}, nil
+}
+
+// summarizeMessage 生成消息的可读描述，离线签名时没有节点无法解析参数，所以在构造时生成
+func summarizeMessage(ctx context.Context, api v0api.FullNode, msg *types.Message) []string {
+	summary := []string{fmt.Sprintf("From: %s%s", msg.From, addressLabel(msg.From))}
+	summary = append(summary, describeCall(ctx, api, msg.To, msg.Value, msg.Method, msg.Params)...)
+	return append(summary,
+		fmt.Sprintf("Nonce: %d", msg.Nonce),
+		fmt.Sprintf("最大手续费: %s", types.FIL(worstCaseFee(msg))),
+	)
+}
+
+// printMessage 打印解析后的消息
+func printMessage(ctx context.Context, api v0api.FullNode, msg *types.Message) {
+	fmt.Println()
+	for _, line := range summarizeMessage(ctx, api, msg) {
+		fmt.Println(line)
+	}
+	fmt.Printf("GasLimit: %d, GasFeeCap: %s, GasPremium: %s\n\n", msg.GasLimit, msg.GasFeeCap, msg.GasPremium)
+}
+
+// describeCall 解析调用的方法名和参数
+func describeCall(ctx context.Context, api v0api.FullNode, to address.Address, value abi.TokenAmount, method abi.MethodNum, params []byte) []string {
+	methodName := fmt.Sprintf("%d", method)
+	var paramStr string
+	if act, err := api.StateGetActor(ctx, to, types.EmptyTSK); err == nil {
+		if m, ok := consensus.NewActorRegistry().Methods[act.Code][method]; ok {
+			methodName = fmt.Sprintf("%s(%d)", m.Name, method)
+		}
+		if len(params) > 0 {
+			if p, err := api.StateDecodeParams(ctx, to, method, params, types.EmptyTSK); err == nil {
+				if b, err := json.Marshal(p); err == nil {
+					paramStr = string(b)
+				}
+			}
+		}
+	}
+
+	desc := []string{
+		fmt.Sprintf("To: %s%s", to, addressLabel(to)),
+		fmt.Sprintf("Value: %s", types.FIL(value)),
+		fmt.Sprintf("Method: %s", methodName),
+	}
+	if paramStr != "" {
+		desc = append(desc, fmt.Sprintf("Params: %s", paramStr))
+	}
+	return desc
+}
+
+func writeMessageBundle(path string, bundle *messageBundle) error {
+	b, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+func readMessageBundle(path string) (*messageBundle, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var bundle messageBundle
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		return nil, xerrors.Errorf("解析消息文件失败: %w", err)
+	}
+	if bundle.Version != bundleVersion {
+		return nil, xerrors.Errorf("不支持的消息文件版本 %d", bundle.Version)
+	}
+	if bundle.Message == nil {
+		return nil, xerrors.New("消息文件中没有消息")
+	}
+	if bundle.Network != activeNetwork {
+		return nil, xerrors.Errorf("消息文件是为 %s 网络构造的，当前网络为 %s", bundle.Network, activeNetwork)
+	}
+	return &bundle, nil
+}
+
+// printMessageBundle 按文件中的消息本身显示内容，Summary 可能被修改过，不作为核对依据
+func printMessageBundle(bundle *messageBundle) {
+	msg := bundle.Message
+	fmt.Printf("网络: %s (%s)\n", bundle.Network, bundle.NetworkName)
+	fmt.Printf("构造时间: %s\n", bundle.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("From: %s%s\n", msg.From, addressLabel(msg.From))
+	fmt.Printf("To: %s%s\n", msg.To, addressLabel(msg.To))
+	fmt.Printf("Value: %s\n", types.FIL(msg.Value))
+	fmt.Printf("Method: %d\n", msg.Method)
+	if len(msg.Params) > 0 {
+		fmt.Printf("Params: %x\n", msg.Params)
+	}
+	fmt.Printf("Nonce: %d\n", msg.Nonce)
+	printFee(msg)
+	fmt.Printf("消息: %s\n", msg.Cid())
+}
+
+var signBundleCmd = &cli.Command{
+	Name:      "sign-bundle",
+	Usage:     "在离线机器上对 --unsigned-out 生成的消息文件签名",
+	ArgsUsage: "<unsigned file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "签名后的消息文件，默认覆盖原文件",
+		},
+		yesFlag,
+	},
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定消息文件")
+			return fmt.Errorf("必须指定消息文件")
+		}
+
+		bundle, err := readMessageBundle(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if bundle.Signature != nil {
+			fmt.Println("消息文件已经签名")
+			return fmt.Errorf("消息文件已经签名")
+		}
+
+		printMessageBundle(bundle)
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+
+		sb, err := signMessage(bundle.Message.Cid().Bytes(), bundle.Message.From)
+		if err != nil {
+			fmt.Printf("签名失败， err:%v\n", err)
+			return xerrors.Errorf("签名失败: %w", err)
+		}
+		bundle.Signature = sb
+
+		out := cctx.String("out")
+		if out == "" {
+			out = cctx.Args().First()
+		}
+		if err := writeMessageBundle(out, bundle); err != nil {
+			fmt.Printf("写入签名消息(%s)失败，err:%v\n", out, err)
+			return err
+		}
+
+		fmt.Printf("签名完成，已写入 %s，请在联网机器上执行 broadcast 推送\n", out)
+		return nil
+	},
+}
+
+var broadcastCmd = &cli.Command{
+	Name:      "broadcast",
+	Usage:     "校验 sign-bundle 签名后的消息文件并推送上链",
+	ArgsUsage: "<signed file>",
+	Flags: []cli.Flag{
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		yesFlag,
+	},
+	Before: func(cctx *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定消息文件")
+			return fmt.Errorf("必须指定消息文件")
+		}
+
+		bundle, err := readMessageBundle(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if bundle.Signature == nil {
+			fmt.Println("消息文件还没有签名，请先执行 sign-bundle")
+			return fmt.Errorf("消息文件还没有签名")
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		name, err := api.StateNetworkName(ctx)
+		if err != nil {
+			fmt.Printf("查询节点网络失败，err:%v\n", err)
+			return err
+		}
+		if string(name) != bundle.NetworkName {
+			fmt.Printf("消息文件是为 %s 构造的，节点所在网络为 %s\n", bundle.NetworkName, name)
+			return xerrors.Errorf("network mismatch: bundle %s, node %s", bundle.NetworkName, name)
+		}
+
+		printMessageBundle(bundle)
+
+		smsg := &types.SignedMessage{Message: *bundle.Message, Signature: *bundle.Signature}
+		signer, err := resolveSigner(cctx, smsg.Message.From)
+		if err != nil {
+			return err
+		}
+		if err := consensus.AuthenticateMessage(smsg, signer); err != nil {
+			fmt.Println("签名验证失败,", err)
+			return err
+		}
+
+		a, err := api.StateGetActor(ctx, smsg.Message.From, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取获取%s地址的nonce失败，err:%v\n", smsg.Message.From, err)
+			return err
+		}
+		if a.Nonce > smsg.Message.Nonce {
+			fmt.Printf("消息的nonce(%d)已经被使用，链上nonce为 %d，请重新构造消息\n", smsg.Message.Nonce, a.Nonce)
+			return xerrors.Errorf("stale nonce %d, actor nonce %d", smsg.Message.Nonce, a.Nonce)
+		}
+
+		if dryRun {
+			return simulateMessage(ctx, api, &smsg.Message)
+		}
+
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+
+		msgCid, err := api.MpoolPush(ctx, smsg)
+		if err != nil {
+			fmt.Printf("推送消息上链失败，err:%v\n", err)
+			return err
+		}
+		recordMessage(cctx, smsg, cid.Undef)
+
+		fmt.Println("Message CID:", msgCid.String())
+
+		if cctx.Bool(waitFlag.Name) {
+			if _, err := waitMessage(ctx, cctx, api, msgCid); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
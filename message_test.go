@@ -0,0 +1,35 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageBundleNetwork(t *testing.T) {
+	defer func() { activeNetwork = "" }()
+
+	to, err := address.NewIDAddress(1000)
+	assert.Nil(t, err)
+
+	path := filepath.Join(t.TempDir(), "unsigned.json")
+	activeNetwork = networkCalibnet
+	assert.Nil(t, writeMessageBundle(path, &messageBundle{
+		Version:     bundleVersion,
+		Network:     activeNetwork,
+		NetworkName: "calibrationnet",
+		Message:     &types.Message{From: to, To: to, Value: types.NewInt(1), Nonce: 7},
+	}))
+
+	bundle, err := readMessageBundle(path)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), bundle.Message.Nonce)
+	assert.Nil(t, bundle.Signature)
+
+	activeNetwork = networkMainnet
+	_, err = readMessageBundle(path)
+	assert.NotNil(t, err)
+}
@@ -17,9 +17,9 @@ import (
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/builtin"
 	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/api/v1api"
 	"github.com/filecoin-project/lotus/blockstore"
-	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/build/buildconstants"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/actors/adt"
@@ -34,6 +34,7 @@ import (
 	power2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/power"
 	power6 "github.com/filecoin-project/specs-actors/v6/actors/builtin/power"
 	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/libp2p/go-libp2p-core/crypto"
@@ -45,14 +46,24 @@ import (
 
 var withdrawCmd = &cli.Command{
 	Name:      "withdraw",
-	Usage:     "矿工提现,例如 withdraw f02420 100, 如果不填写提现金额，则提取miner所有余额",
+	Usage:     "矿工提现,例如 withdraw f02420 100, 如果不填写提现金额，则提取miner所有余额。指定 --all-tracked 或 --from-file 时批量提现多个矿工",
 	ArgsUsage: "[minerId (eg f01000) ] [amount (FIL)]",
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
+	Flags: []cli.Flag{
+		withdrawAllTrackedFlag,
+		withdrawFromFileFlag,
+		withdrawLeaveFlag,
+		msigFromFlag,
+		unsignedOutFlag,
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		/**
 		1 获取nonce，
@@ -65,7 +76,11 @@ var withdrawCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if cctx.Bool(withdrawAllTrackedFlag.Name) || cctx.IsSet(withdrawFromFileFlag.Name) {
+			return withdrawMany(cctx)
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
@@ -74,7 +89,7 @@ var withdrawCmd = &cli.Command{
 
 		ctx := lcli.ReqContext(cctx)
 
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.Args().First(), err)
 			return err
@@ -98,12 +113,11 @@ var withdrawCmd = &cli.Command{
 			return err
 		}
 
-		owner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
+		ownerIsMsig, err := isMultisig(ctx, api, mi.Owner)
 		if err != nil {
-			fmt.Printf("%s\t%s: error getting account key: %s\n", "owner", owner, err)
+			fmt.Printf("读取owner(%s)失败。 %v\n", mi.Owner, err)
 			return err
 		}
-		fmt.Printf("--->%+v", owner)
 
 		// 获取矿工可用余额
 		available, err := api.StateMinerAvailableBalance(ctx, maddr, types.EmptyTSK)
@@ -112,6 +126,34 @@ var withdrawCmd = &cli.Command{
 			return err
 		}
 
+		// 可用余额已经扣除了欠费，WithdrawBalance 会先用余额偿还欠费
+		warnFeeDebt(ctx, api, maddr)
+
+		// 受益人不是owner时，提现金额受受益人剩余额度和有效期限制，提现的FIL转给受益人
+		var epoch abi.ChainEpoch
+		if mi.Beneficiary != mi.Owner && mi.BeneficiaryTerm != nil {
+			head, err := api.ChainHead(ctx)
+			if err != nil {
+				fmt.Printf("读取链高度失败。 %v\n", err)
+				return err
+			}
+			epoch = head.Height()
+			limit := withdrawLimit(mi, available, epoch)
+			quota, _ := beneficiaryAvailable(mi, epoch)
+			fmt.Printf("受益人 %s%s，剩余额度 %s，过期高度 %s，提现金额将转给受益人\n", mi.Beneficiary, addressLabel(mi.Beneficiary), types.FIL(quota), EpochTime(epoch, mi.BeneficiaryTerm.Expiration))
+			if limit.IsZero() && !available.IsZero() {
+				fmt.Println("受益人额度已用完或已过期，不能提现")
+				return xerrors.Errorf("beneficiary quota exhausted or expired")
+			}
+		}
+
+		leave, err := types.ParseFIL(cctx.String(withdrawLeaveFlag.Name))
+		if err != nil {
+			fmt.Println("leave 不正确,", err)
+			return err
+		}
+		available = withdrawAmount(mi, epoch, nil, available, abi.TokenAmount(leave))
+
 		amount := available
 		f, err := types.ParseFIL(cctx.Args().Get(1))
 		if err == nil {
@@ -126,10 +168,18 @@ var withdrawCmd = &cli.Command{
 			return xerrors.Errorf("can't withdraw more funds than available; requested: %s; available: %s", amount, available)
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
+		// owner是多签时由本地签名人发起提案或确认已有的提案
+		if ownerIsMsig {
+			if err := msigWithdraw(ctx, cctx, api, mi.Owner, maddr, amount, cctx.Args().Get(1) == ""); err != nil {
+				fmt.Println(err)
+				return err
+			}
+			return nil
+		}
+
+		owner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
 		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
+			fmt.Printf("%s\t%s: error getting account key: %s\n", "owner", mi.Owner, err)
 			return err
 		}
 
@@ -141,42 +191,21 @@ var withdrawCmd = &cli.Command{
 			return err
 		}
 
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
 			To:     maddr,
 			From:   owner,
 			Value:  types.NewInt(0),
 			Method: builtin.MethodsMiner.WithdrawBalance,
-			Nonce:  a.Nonce,
 			Params: params,
-		}, nil, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-			return xerrors.Errorf("GasEstimateMessageGas error: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
+		}, false)
 		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		if msgCid == cid.Undef {
+			return nil
+		}
 
-		fmt.Printf("Requested rewards withdrawal in message %s\n", cid.String())
+		fmt.Printf("Requested rewards withdrawal in message %s\n", msgCid.String())
 
 		return nil
 	},
@@ -186,18 +215,17 @@ var controlSetCmd = &cli.Command{
 	Name:      "control-set",
 	Usage:     "Set control address(-es)",
 	ArgsUsage: "[minerId (eg. f021704)] [...address]",
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before:    messageBefore,
 	Flags: []cli.Flag{
-		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "Actually send transaction performing the action",
-			Value: false,
-		},
+		unsignedOutFlag,
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		//nodeApi, closer, err := GetStorageMinerAPI(cctx)
@@ -210,7 +238,7 @@ var controlSetCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -220,7 +248,7 @@ var controlSetCmd = &cli.Command{
 		ctx := lcli.ReqContext(cctx)
 
 		//maddr, err := nodeApi.ActorAddress(ctx)
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -248,7 +276,7 @@ var controlSetCmd = &cli.Command{
 		var toSet []address.Address
 
 		for i, as := range cctx.Args().Tail() {
-			a, err := address.NewFromString(as)
+			a, err := parseAddress(as)
 			if err != nil {
 				fmt.Println(err)
 				return xerrors.Errorf("parsing address %d: %w", i, err)
@@ -280,11 +308,6 @@ var controlSetCmd = &cli.Command{
 			}
 		}
 
-		if !cctx.Bool("really-do-it") {
-			fmt.Println("Pass --really-do-it to actually execute this action")
-			return nil
-		}
-
 		cwp := &miner2.ChangeWorkerAddressParams{
 			NewWorker:       mi.Worker,
 			NewControlAddrs: toSet,
@@ -296,55 +319,25 @@ var controlSetCmd = &cli.Command{
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
 		owner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
 		if err != nil {
 			fmt.Printf("%s\t%s: error getting account key: %s\n", "owner", owner, err)
 			return err
 		}
 
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
 			To:     maddr,
 			From:   owner,
 			Value:  big.Zero(),
 			Method: builtin.MethodsMiner.ChangeWorkerAddress,
-			Nonce:  a.Nonce,
 			Params: sp,
-		}, nil, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-			return xerrors.Errorf("GasEstimateMessageGas error: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
+		}, false)
 		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
-
-		fmt.Println("Message CID:", cid.String())
+		if msgCid == cid.Undef {
+			return nil
+		}
 
 		return nil
 	},
@@ -355,28 +348,21 @@ var setOwnerCmd = &cli.Command{
 	Usage:     "设置矿工的owner地址 (设置过程中这个命令需要被执行两次, 第一次用旧的ownr地址发送, 第二次用新的owner地址发送)",
 	ArgsUsage: "[miner 新owner地址 发送地址]",
 	Flags: []cli.Flag{
-		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "确定命令，防止误操作",
-			Value: false,
-		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		if !cctx.Bool("really-do-it") {
-			fmt.Println("Pass --really-do-it to actually execute this action")
-			return nil
-		}
-
 		if cctx.NArg() != 3 {
 			fmt.Println("必须输入矿工编号，新的owner地址，和发送钱包地址")
 			return fmt.Errorf("must pass miner id, new owner address and sender address")
@@ -388,7 +374,7 @@ var setOwnerCmd = &cli.Command{
 		//}
 		//defer closer()
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
@@ -397,7 +383,7 @@ var setOwnerCmd = &cli.Command{
 
 		ctx := lcli.ReqContext(cctx)
 
-		na, err := address.NewFromString(cctx.Args().Get(1))
+		na, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			fmt.Printf("解析新的owner地址失败。%v\n", err)
 			return err
@@ -409,7 +395,7 @@ var setOwnerCmd = &cli.Command{
 			return err
 		}
 
-		fa, err := address.NewFromString(cctx.Args().Get(2))
+		fa, err := parseAddress(cctx.Args().Get(2))
 		if err != nil {
 			fmt.Printf("解析新的发送地址失败。%v\n", err)
 			return err
@@ -422,7 +408,7 @@ var setOwnerCmd = &cli.Command{
 		}
 
 		//maddr, err := nodeApi.ActorAddress(ctx)
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println("读取矿工地址失败", err)
 			return err
@@ -445,60 +431,27 @@ var setOwnerCmd = &cli.Command{
 			return xerrors.Errorf("serializing params: %w", err)
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, fromAddrId, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
 			From:   fa,
 			To:     maddr,
 			Method: builtin.MethodsMiner.ChangeOwnerAddress,
 			Value:  big.Zero(),
 			Params: sp,
-			Nonce:  a.Nonce,
-		}, nil, types.EmptyTSK)
-		if err != nil {
-			fmt.Println("评估消息gas失败，", err)
-			return xerrors.Errorf("mpool push: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v\n", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
+		}, false)
 		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
-
-		fmt.Println("Message CID:", cid)
+		if msgCid == cid.Undef {
+			return nil
+		}
 
 		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, cid, build.MessageConfidence)
+		wait, err := waitMessage(ctx, cctx, api, msgCid)
 		if err != nil {
-			fmt.Println("等效消息返回失败,", err)
-			return err
-		}
-
-		// check it executed successfully
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Println("发送修改owner地址失败!")
+			// 消息已上链但执行失败
+			if wait != nil {
+				fmt.Println("发送修改owner地址失败!")
+			}
 			return err
 		}
 
@@ -539,7 +492,7 @@ var controlListCmd = &cli.Command{
 		//}
 		//defer closer()
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -549,7 +502,7 @@ var controlListCmd = &cli.Command{
 		ctx := lcli.ReqContext(cctx)
 
 		//maddr, err := nodeApi.ActorAddress(ctx)
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -700,18 +653,16 @@ var proposeChangeWorker = &cli.Command{
 	Usage:     "修改worker钱包地址,（worker的地址必须是bls类型）",
 	ArgsUsage: "[矿工地址, 新worker地址]",
 	Flags: []cli.Flag{
-		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "确认执行的命令",
-			Value: false,
-		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
@@ -722,7 +673,7 @@ var proposeChangeWorker = &cli.Command{
 			return fmt.Errorf("must pass address of new worker address")
 		}
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println("连接FULLNODE_API_INFO失败")
 			return fmt.Errorf("must pass address of new worker address")
@@ -732,7 +683,7 @@ var proposeChangeWorker = &cli.Command{
 		ctx := lcli.ReqContext(cctx)
 
 		// 目标地址
-		na, err := address.NewFromString(cctx.Args().Get(1))
+		na, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			fmt.Println("获取新的worker地址失败")
 			return err
@@ -746,7 +697,7 @@ var proposeChangeWorker = &cli.Command{
 
 		// 矿工地址
 		//maddr, err := nodeApi.ActorAddress(ctx)
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println("解析矿工地址失败")
 			return err
@@ -770,11 +721,6 @@ var proposeChangeWorker = &cli.Command{
 			}
 		}
 
-		if !cctx.Bool("really-do-it") {
-			fmt.Fprintln(cctx.App.Writer, "请输入 --really-do-it 参数执行命令")
-			return nil
-		}
-
 		cwp := &miner2.ChangeWorkerAddressParams{
 			NewWorker:       newAddr,
 			NewControlAddrs: mi.ControlAddresses,
@@ -792,59 +738,29 @@ var proposeChangeWorker = &cli.Command{
 			return nil
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
 			From:   realOwner,
 			To:     maddr,
 			Method: builtin.MethodsMiner.ChangeWorkerAddress,
 			Value:  big.Zero(),
 			Params: sp,
-			Nonce:  a.Nonce,
-		}, nil, types.EmptyTSK)
-		if err != nil {
-			return xerrors.Errorf("GasEstimateMessageGas: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v\n", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
+		}, false)
 		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		if msgCid == cid.Undef {
+			return nil
+		}
 
-		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", cid)
+		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", msgCid)
 
 		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, cid, build.MessageConfidence)
+		wait, err := waitMessage(ctx, cctx, api, msgCid)
 		if err != nil {
-			fmt.Println("等待消息返回失败")
-			return err
-		}
-
-		// check it executed successfully
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Fprintln(cctx.App.Writer, "Propose worker change failed!")
+			// 消息已上链但执行失败
+			if wait != nil {
+				fmt.Fprintln(cctx.App.Writer, "Propose worker change failed!")
+			}
 			return err
 		}
 
@@ -870,18 +786,16 @@ var confirmChangeWorker = &cli.Command{
 	Usage:     "Confirm a worker address change",
 	ArgsUsage: "[旷工地址 newaddress]",
 	Flags: []cli.Flag{
-		&cli.BoolFlag{
-			Name:  "really-do-it",
-			Usage: "Actually send transaction performing the action",
-			Value: false,
-		},
-	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
@@ -892,7 +806,7 @@ var confirmChangeWorker = &cli.Command{
 			return fmt.Errorf("must pass address of new worker address")
 		}
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -901,7 +815,7 @@ var confirmChangeWorker = &cli.Command{
 
 		ctx := lcli.ReqContext(cctx)
 
-		na, err := address.NewFromString(cctx.Args().Get(1))
+		na, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -909,7 +823,7 @@ var confirmChangeWorker = &cli.Command{
 
 		// 矿工地址
 		//maddr, err := nodeApi.ActorAddress(ctx)
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println("解析矿工地址失败")
 			return err
@@ -942,72 +856,34 @@ var confirmChangeWorker = &cli.Command{
 			return xerrors.Errorf("worker key change cannot be confirmed until %d, current height is %d", mi.WorkerChangeEpoch, head.Height())
 		}
 
-		if !cctx.Bool("really-do-it") {
-			fmt.Fprintln(cctx.App.Writer, "Pass --really-do-it to actually execute this action")
-			return nil
-		}
-
 		realOwner, err := api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
 		if err != nil {
 			fmt.Printf("%s\t%s: error getting account key: %s\n", maddr, mi.Owner, err)
 			return nil
 		}
 
-		// 获取nonce
-		a, err := api.StateGetActor(ctx, mi.Owner, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return err
-		}
-
-		// 构造msg
-		msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
+		msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
 			From:   realOwner,
 			To:     maddr,
 			Method: builtin.MethodsMiner.ConfirmChangeWorkerAddress,
 			Value:  big.Zero(),
-			Nonce:  a.Nonce,
-		}, nil, types.EmptyTSK)
-
+		}, false)
 		if err != nil {
-			fmt.Println(xerrors.Errorf("GasEstimateMessageGas: %w", err))
-			return xerrors.Errorf("GasEstimateMessageGas: %w", err)
-		}
-
-		fmt.Printf("\n%+v\n", msg)
-
-		mb, err := msg.ToStorageBlock()
-		if err != nil {
-			fmt.Printf("序列化消息失败， err:%v\n", err)
-			return xerrors.Errorf("serializing message: %w", err)
-		}
-
-		// 签名
-		sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-		if err != nil {
-			fmt.Printf("签名失败， err:%v\n", err)
-			return xerrors.Errorf("签名失败: %w", err)
-		}
-
-		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-		if err != nil {
-			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		if msgCid == cid.Undef {
+			return nil
+		}
 
-		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", cid)
+		fmt.Fprintln(cctx.App.Writer, "Propose Message CID:", msgCid)
 
 		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, cid, build.MessageConfidence)
+		wait, err := waitMessage(ctx, cctx, api, msgCid)
 		if err != nil {
-			fmt.Println(err)
-			return err
-		}
-
-		// check it executed successfully
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Fprintln(cctx.App.Writer, "Worker change failed!")
+			// 消息已上链但执行失败
+			if wait != nil {
+				fmt.Fprintln(cctx.App.Writer, "Worker change failed!")
+			}
 			return err
 		}
 
@@ -1031,12 +907,7 @@ var newMinerCmd = &cli.Command{
 	Name:  "newMiner",
 	Usage: "创建新的miner : newMiner --owner fxxx --worker f3xxxx",
 	//ArgsUsage: " --owner fxxxx",
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Flags: []cli.Flag{
 		&cli.StringFlag{
 			Name:     "owner",
@@ -1050,6 +921,13 @@ var newMinerCmd = &cli.Command{
 			Usage:    "指定 worker  地址，必须是f3地址",
 			Required: true,
 		},
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 
@@ -1058,7 +936,7 @@ var newMinerCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		api, closer, err := lcli.GetFullNodeAPIV1(cctx)
+		api, closer, err := getFullNodeAPIV1(cctx)
 		if err != nil {
 			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
 			return err
@@ -1168,7 +1046,10 @@ var newMinerCmd = &cli.Command{
 		gasPrice, _ := types.BigFromString("0")
 		confidence := buildconstants.MessageConfidence
 		if err := storageMinerInit(ctx, cctx, api, r, ssize, gasPrice, confidence); err != nil {
-			fmt.Println("Failed to initialize lotus-miner: ", err)
+			canceled := xerrors.Is(err, errCanceled)
+			if !canceled {
+				fmt.Println("Failed to initialize lotus-miner: ", err)
+			}
 			path, err := homedir.Expand(repoPath)
 			if err != nil {
 				fmt.Println(err)
@@ -1178,6 +1059,10 @@ var newMinerCmd = &cli.Command{
 			if err := os.RemoveAll(path); err != nil {
 				fmt.Println("Failed to clean up failed storage repo: ", err)
 			}
+			if canceled {
+				fmt.Println("没有创建矿工")
+				return nil
+			}
 			fmt.Println(err)
 			return xerrors.Errorf("Storage-miner init failed")
 		}
@@ -1219,6 +1104,9 @@ func storageMinerInit(ctx context.Context, cctx *cli.Context, api v1api.FullNode
 
 	var addr address.Address
 	a, err := createStorageMiner(ctx, api, ssize, peerid, gasPrice, confidence, cctx)
+	if xerrors.Is(err, errCanceled) {
+		return err
+	}
 	if err != nil {
 		fmt.Println(err)
 		return xerrors.Errorf("creating miner failed: %w", err)
@@ -1265,11 +1153,11 @@ func makeHostKey(lr repo.LockedRepo) (crypto.PrivKey, error) {
 	return pk, nil
 }
 
-func createStorageMiner(ctx context.Context, api v1api.FullNode, ssize abi.SectorSize, peerid peer.ID, _ types.BigInt, confidence uint64, cctx *cli.Context) (address.Address, error) {
+func createStorageMiner(ctx context.Context, api v1api.FullNode, ssize abi.SectorSize, peerid peer.ID, _ types.BigInt, _ uint64, cctx *cli.Context) (address.Address, error) {
 	var err error
 	var owner address.Address
 	if cctx.String("owner") != "" {
-		owner, err = address.NewFromString(cctx.String("owner"))
+		owner, err = parseAddress(cctx.String("owner"))
 	} else {
 		fmt.Println("必须指定 --owner ")
 		return address.Undef, err
@@ -1277,7 +1165,7 @@ func createStorageMiner(ctx context.Context, api v1api.FullNode, ssize abi.Secto
 
 	worker := owner
 	if cctx.String("worker") != "" {
-		worker, err = address.NewFromString(cctx.String("worker"))
+		worker, err = parseAddress(cctx.String("worker"))
 	} else {
 		fmt.Println("必须指定 --worker 且worker必须是 f3 地址")
 		return address.Undef, err
@@ -1313,65 +1201,26 @@ func createStorageMiner(ctx context.Context, api v1api.FullNode, ssize abi.Secto
 		return address.Undef, err
 	}
 
-	// 本地签名操作： 1 获取地址的nonce， 2 评估gas，3 本地签名，4 推送消息
-	// 1 获取nonce
-	a, err := api.StateGetActor(ctx, owner, types.EmptyTSK)
-	if err != nil {
-		fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-		return address.Undef, err
-	}
-
-	// 2 评估gas
-	msg, err := api.GasEstimateMessageGas(ctx, &types.Message{
-		From:  owner,
-		To:    power.Address,
-		Value: big.Zero(),
-
+	msgCid, err := sendMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, &types.Message{
+		From:   owner,
+		To:     power.Address,
+		Value:  big.Zero(),
 		Method: power.Methods.CreateMiner,
 		Params: params,
-		Nonce:  a.Nonce,
-	}, nil, types.EmptyTSK)
-	if err != nil {
-		fmt.Println("评估消息gas失败，", err)
-		return address.Undef, xerrors.Errorf("mpool push: %w", err)
-	}
-
-	fmt.Printf("\n%+v\n", msg)
-
-	mb, err := msg.ToStorageBlock()
+	}, false)
 	if err != nil {
-		fmt.Printf("序列化消息失败， err:%v\n", err)
-		return address.Undef, xerrors.Errorf("serializing message: %w", err)
-	}
-
-	// 3 签名
-	sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-	if err != nil {
-		fmt.Printf("签名失败， err:%v\n", err)
-		return address.Undef, xerrors.Errorf("签名失败: %w", err)
-	}
-
-	// 4 推送消息
-	cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-	if err != nil {
-		fmt.Printf("推送消息上链失败，err:%v\n", err)
 		return address.Undef, xerrors.Errorf("pushing createMiner message: %w", err)
 	}
+	// 模拟执行或者取消发送
+	if msgCid == cid.Undef {
+		return address.Undef, errCanceled
+	}
 
-	fmt.Printf("Pushed CreateMiner message: %s\n", cid.String())
-	fmt.Println("Waiting for confirmation")
-
-	mw, err := api.StateWaitMsg(ctx, cid, confidence, lapi.LookbackNoLimit, true)
+	mw, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid)
 	if err != nil {
-		fmt.Println(xerrors.Errorf("waiting for createMiner message: %w", err))
 		return address.Undef, xerrors.Errorf("waiting for createMiner message: %w", err)
 	}
 
-	if mw.Receipt.ExitCode != 0 {
-		fmt.Println(xerrors.Errorf("create miner failed: exit code %d", mw.Receipt.ExitCode))
-		return address.Undef, xerrors.Errorf("create miner failed: exit code %d", mw.Receipt.ExitCode)
-	}
-
 	var retval power2.CreateMinerReturn
 	if err := retval.UnmarshalCBOR(bytes.NewReader(mw.Receipt.Return)); err != nil {
 		fmt.Println(err)
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// minerFeeDebt 读取矿工状态中的欠费
+func minerFeeDebt(ctx context.Context, api v0api.FullNode, maddr address.Address) (abi.TokenAmount, error) {
+	mact, err := api.StateGetActor(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return big.Zero(), err
+	}
+	tbs := blockstore.NewTieredBstore(blockstore.NewAPIBlockstore(api), blockstore.NewMemory())
+	mas, err := miner.Load(adt.WrapStore(ctx, cbor.NewCborStore(tbs)), mact)
+	if err != nil {
+		return big.Zero(), err
+	}
+	return mas.FeeDebt()
+}
+
+// warnFeeDebt 矿工有欠费时提示，提现时可用余额已经扣除了欠费，提现金额不会超过可用余额
+func warnFeeDebt(ctx context.Context, api v0api.FullNode, maddr address.Address) {
+	debt, err := minerFeeDebt(ctx, api, maddr)
+	if err != nil || debt.IsZero() {
+		return
+	}
+	fmt.Printf("注意: 矿工 %s 欠费 %s，提现时会先偿还欠费，可用余额已扣除欠费。也可以执行 miner repay-debt %s 单独还清\n", maddr, types.FIL(debt), maddr)
+}
+
+var minerRepayDebtCmd = &cli.Command{
+	Name:      "repay-debt",
+	Usage:     "偿还矿工欠费。默认由owner发送 RepayDebt 并附带欠费金额；指定 --fund-from 时先从本地地址转入矿工再发送 RepayDebt",
+	ArgsUsage: "<minerId>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "sender",
+			Usage: "发送 RepayDebt 的地址: owner、worker",
+			Value: "owner",
+		},
+		&cli.StringFlag{
+			Name:  "fund-from",
+			Usage: "先从这个本地地址把欠费金额转入矿工",
+		},
+		&cli.StringFlag{
+			Name:  "margin",
+			Usage: "在欠费之外多转入的金额(FIL)，多余部分留在矿工可用余额中",
+			Value: "0",
+		},
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.Args().First(), err)
+			return err
+		}
+		margin, err := types.ParseFIL(cctx.String("margin"))
+		if err != nil {
+			fmt.Println("margin 不正确,", err)
+			return err
+		}
+
+		debt, err := minerFeeDebt(ctx, api, maddr)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)欠费失败。 %v\n", maddr, err)
+			return err
+		}
+		if debt.IsZero() {
+			fmt.Printf("矿工 %s 没有欠费\n", maddr)
+			return nil
+		}
+		amount := big.Add(debt, abi.TokenAmount(margin))
+		fmt.Printf("矿工 %s 欠费 %s，本次转入 %s\n", maddr, types.FIL(debt), types.FIL(amount))
+
+		mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)信息失败。 %v\n", maddr, err)
+			return err
+		}
+		var sender address.Address
+		switch cctx.String("sender") {
+		case "owner":
+			sender = mi.Owner
+		case "worker":
+			sender = mi.Worker
+		default:
+			fmt.Printf("--sender 的值 %s 不正确，可选: owner, worker\n", cctx.String("sender"))
+			return fmt.Errorf("--sender 的值不正确")
+		}
+		if ms, err := isMultisig(ctx, api, sender); err != nil {
+			fmt.Println(err)
+			return err
+		} else if ms {
+			fmt.Printf("%s 是多签地址，请使用 --sender worker\n", sender)
+			return fmt.Errorf("sender 是多签地址")
+		}
+		senderKey, err := api.StateAccountKey(ctx, sender, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("查询 %s 的公钥地址失败。 %v\n", sender, err)
+			return err
+		}
+
+		value := amount
+		if cctx.IsSet("fund-from") {
+			from, err := parseAddress(cctx.String("fund-from"))
+			if err != nil {
+				fmt.Println("fund-from 地址不正确,", err)
+				return err
+			}
+
+			fmt.Printf("\n从 %s%s 转入矿工 %s\n", from, addressLabel(from), maddr)
+			c, err := sendMessage(ctx, cctx, api, &types.Message{
+				From:  from,
+				To:    maddr,
+				Value: amount,
+			}, false)
+			if err != nil {
+				return err
+			}
+			if c == cid.Undef {
+				if dryRun {
+					fmt.Println("转入上链后再由", senderKey, "发送 RepayDebt")
+				}
+				return nil
+			}
+			if _, err := waitMessage(ctx, cctx, api, c); err != nil {
+				return err
+			}
+			value = big.Zero()
+		}
+
+		fmt.Printf("\n由 %s %s 发送 RepayDebt\n", cctx.String("sender"), senderKey)
+		c, err := sendMessage(ctx, cctx, api, &types.Message{
+			From:   senderKey,
+			To:     maddr,
+			Value:  value,
+			Method: builtin.MethodsMiner.RepayDebt,
+		}, false)
+		if err != nil {
+			return err
+		}
+		if c == cid.Undef {
+			return nil
+		}
+		if _, err := waitMessage(ctx, cctx, api, c); err != nil {
+			return err
+		}
+
+		left, err := minerFeeDebt(ctx, api, maddr)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)欠费失败。 %v\n", maddr, err)
+			return err
+		}
+		if !left.IsZero() {
+			fmt.Printf("矿工 %s 仍欠费 %s\n", maddr, types.FIL(left))
+			return xerrors.Errorf("fee debt not cleared: %s", types.FIL(left))
+		}
+		fmt.Printf("矿工 %s 欠费已还清\n", maddr)
+		return nil
+	},
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin"
+	miner15 "github.com/filecoin-project/go-state-types/builtin/v15/miner"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var minerCmd = &cli.Command{
+	Name:  "miner",
+	Usage: "矿工资金工具",
+	Subcommands: []*cli.Command{
+		minerStatusCmd,
+		minerRepayDebtCmd,
+	},
+}
+
+// 锁仓释放统计的时间段(天)
+var vestingHorizons = []int{1, 7, 30, 90, 180, 540}
+
+type minerAddrStatus struct {
+	Role    string
+	ID      string
+	Key     string
+	Balance types.FIL
+}
+
+type minerVestingFund struct {
+	Epoch  abi.ChainEpoch
+	Time   string
+	Amount types.FIL
+}
+
+type minerVestingBucket struct {
+	Days   int
+	Amount types.FIL
+}
+
+// minerStatus miner status 的输出，--json 时直接序列化
+type minerStatus struct {
+	Miner             string
+	Height            abi.ChainEpoch
+	Balance           types.FIL
+	Available         types.FIL
+	InitialPledge     types.FIL
+	PreCommitDeposits types.FIL
+	LockedVesting     types.FIL
+	FeeDebt           types.FIL
+	Vesting           []minerVestingBucket
+	VestingSchedule   []minerVestingFund `json:",omitempty"`
+	Addresses         []minerAddrStatus
+}
+
+// vestingBuckets 按时间段累计即将释放的锁仓
+func vestingBuckets(funds []miner15.VestingFund, height abi.ChainEpoch) []minerVestingBucket {
+	var out []minerVestingBucket
+	for _, days := range vestingHorizons {
+		until := height + abi.ChainEpoch(days)*builtin.EpochsInDay
+		sum := big.Zero()
+		for _, f := range funds {
+			if f.Epoch <= until {
+				sum = big.Add(sum, f.Amount)
+			}
+		}
+		out = append(out, minerVestingBucket{Days: days, Amount: types.FIL(sum)})
+	}
+	return out
+}
+
+// loadVestingSchedule 读取矿工的锁仓释放计划。各版本 VestingFunds 的编码相同，统一按 v15 解析
+func loadVestingSchedule(ctx context.Context, api v0api.FullNode, store adt.Store, maddr address.Address) ([]miner15.VestingFund, error) {
+	st, err := api.StateReadState(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := st.State.(map[string]interface{})
+	if !ok {
+		return nil, xerrors.Errorf("unexpected miner state %T", st.State)
+	}
+	link, ok := m["VestingFunds"].(map[string]interface{})
+	if !ok {
+		return nil, xerrors.Errorf("miner state has no VestingFunds")
+	}
+	s, _ := link["/"].(string)
+	c, err := cid.Parse(s)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing VestingFunds cid: %w", err)
+	}
+
+	var funds miner15.VestingFunds
+	if err := store.Get(ctx, c, &funds); err != nil {
+		return nil, xerrors.Errorf("loading vesting funds: %w", err)
+	}
+	return funds.Funds, nil
+}
+
+func getMinerStatus(ctx context.Context, api v0api.FullNode, maddr address.Address, schedule bool) (*minerStatus, error) {
+	head, err := api.ChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("读取矿工信息失败: %w", err)
+	}
+	mact, err := api.StateGetActor(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+
+	tbs := blockstore.NewTieredBstore(blockstore.NewAPIBlockstore(api), blockstore.NewMemory())
+	store := adt.WrapStore(ctx, cbor.NewCborStore(tbs))
+	mas, err := miner.Load(store, mact)
+	if err != nil {
+		return nil, err
+	}
+
+	available, err := api.StateMinerAvailableBalance(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("读取可用余额失败: %w", err)
+	}
+	lf, err := mas.LockedFunds()
+	if err != nil {
+		return nil, xerrors.Errorf("getting locked funds: %w", err)
+	}
+	debt, err := mas.FeeDebt()
+	if err != nil {
+		return nil, xerrors.Errorf("getting fee debt: %w", err)
+	}
+	funds, err := loadVestingSchedule(ctx, api, store, maddr)
+	if err != nil {
+		return nil, err
+	}
+
+	ms := &minerStatus{
+		Miner:             maddr.String(),
+		Height:            head.Height(),
+		Balance:           types.FIL(mact.Balance),
+		Available:         types.FIL(available),
+		InitialPledge:     types.FIL(lf.InitialPledgeRequirement),
+		PreCommitDeposits: types.FIL(lf.PreCommitDeposits),
+		LockedVesting:     types.FIL(lf.VestingFunds),
+		FeeDebt:           types.FIL(debt),
+		Vesting:           vestingBuckets(funds, head.Height()),
+	}
+	if schedule {
+		for _, f := range funds {
+			ms.VestingSchedule = append(ms.VestingSchedule, minerVestingFund{
+				Epoch:  f.Epoch,
+				Time:   EpochTimeHuman(head.Height(), f.Epoch),
+				Amount: types.FIL(f.Amount),
+			})
+		}
+	}
+
+	addAddr := func(role string, a address.Address) {
+		as := minerAddrStatus{Role: role, ID: a.String()}
+		if k, err := api.StateAccountKey(ctx, a, types.EmptyTSK); err == nil {
+			as.Key = k.String()
+		}
+		b, err := api.WalletBalance(ctx, a)
+		if err != nil {
+			b = big.Zero()
+		}
+		as.Balance = types.FIL(b)
+		ms.Addresses = append(ms.Addresses, as)
+	}
+	addAddr("owner", mi.Owner)
+	addAddr("worker", mi.Worker)
+	if mi.NewWorker != address.Undef {
+		addAddr("newWorker", mi.NewWorker)
+	}
+	for i, ca := range mi.ControlAddresses {
+		addAddr(fmt.Sprintf("control-%d", i), ca)
+	}
+	if mi.Beneficiary != mi.Owner {
+		addAddr("beneficiary", mi.Beneficiary)
+	}
+	return ms, nil
+}
+
+func (ms *minerStatus) print() error {
+	fmt.Printf("Miner: %s (高度 %d)\n", ms.Miner, ms.Height)
+	fmt.Printf("Balance:             %s\n", ms.Balance)
+	fmt.Printf("Available:           %s\n", ms.Available)
+	fmt.Printf("InitialPledge:       %s\n", ms.InitialPledge)
+	fmt.Printf("PreCommitDeposits:   %s\n", ms.PreCommitDeposits)
+	fmt.Printf("LockedVesting:       %s\n", ms.LockedVesting)
+	if big.Int(ms.FeeDebt).GreaterThan(big.Zero()) {
+		fmt.Printf("FeeDebt:             %s (有欠费时不能提现，可以用 miner repay-debt 偿还)\n", ms.FeeDebt)
+	} else {
+		fmt.Printf("FeeDebt:             %s\n", ms.FeeDebt)
+	}
+
+	fmt.Println("\n锁仓释放:")
+	for _, b := range ms.Vesting {
+		fmt.Printf("  %3d 天内: %s\n", b.Days, b.Amount)
+	}
+	if len(ms.VestingSchedule) > 0 {
+		fmt.Println("\n释放计划:")
+		tw := tablewriter.New(tablewriter.Col("Epoch"), tablewriter.Col("Time"), tablewriter.Col("Amount"))
+		for _, f := range ms.VestingSchedule {
+			tw.Write(map[string]interface{}{"Epoch": f.Epoch, "Time": f.Time, "Amount": f.Amount})
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println()
+	tw := tablewriter.New(tablewriter.Col("Role"), tablewriter.Col("ID"), tablewriter.Col("Key"), tablewriter.Col("Balance"))
+	for _, a := range ms.Addresses {
+		tw.Write(map[string]interface{}{"Role": a.Role, "ID": a.ID, "Key": a.Key, "Balance": a.Balance})
+	}
+	return tw.Flush(os.Stdout)
+}
+
+var minerStatusCmd = &cli.Command{
+	Name:      "status",
+	Usage:     "查看矿工的资金状况：余额、可用余额、质押、预提交押金、锁仓及释放计划、欠费和相关地址余额",
+	ArgsUsage: "<minerId...>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "以JSON格式输出",
+		},
+		&cli.BoolFlag{
+			Name:  "schedule",
+			Usage: "输出完整的锁仓释放计划",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			fmt.Println("必须指定矿工号")
+			return fmt.Errorf("必须指定矿工号")
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		var all []*minerStatus
+		for _, arg := range cctx.Args().Slice() {
+			maddr, err := parseAddress(arg)
+			if err != nil {
+				fmt.Printf("输入miner ID(%s)不正确。 %v\n", arg, err)
+				return err
+			}
+			ms, err := getMinerStatus(ctx, api, maddr, cctx.Bool("schedule"))
+			if err != nil {
+				fmt.Printf("读取矿工(%s)状态失败。 %v\n", maddr, err)
+				return err
+			}
+			all = append(all, ms)
+		}
+
+		if cctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(all)
+		}
+
+		for i, ms := range all {
+			if i > 0 {
+				fmt.Println("\n--------------------------------------------------")
+			}
+			if err := ms.print(); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/builtin"
+	miner15 "github.com/filecoin-project/go-state-types/builtin/v15/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVestingBuckets(t *testing.T) {
+	height := abi.ChainEpoch(1000)
+	funds := []miner15.VestingFund{
+		{Epoch: height + builtin.EpochsInDay/2, Amount: abi.NewTokenAmount(1)},
+		{Epoch: height + 5*builtin.EpochsInDay, Amount: abi.NewTokenAmount(10)},
+		{Epoch: height + 100*builtin.EpochsInDay, Amount: abi.NewTokenAmount(100)},
+	}
+
+	buckets := vestingBuckets(funds, height)
+	assert.Len(t, buckets, len(vestingHorizons))
+	assert.Equal(t, types.FIL(abi.NewTokenAmount(1)), buckets[0].Amount)
+	assert.Equal(t, types.FIL(abi.NewTokenAmount(11)), buckets[1].Amount)
+	assert.Equal(t, types.FIL(abi.NewTokenAmount(11)), buckets[3].Amount)
+	assert.Equal(t, types.FIL(abi.NewTokenAmount(111)), buckets[4].Amount)
+}
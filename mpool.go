@@ -50,17 +50,17 @@ var msgReplaceCmd = &cli.Command{
 			Name:  "fee-limit",
 			Usage: "Spend up to X FIL for this message in units of FIL. Previously when flag was `max-fee` units were in attoFIL. Applicable for auto mode",
 		},
+		yesFlag,
 	},
 	ArgsUsage: "<from> <nonce> | <message-cid>",
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before:    messageBefore,
 	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
@@ -86,7 +86,7 @@ var msgReplaceCmd = &cli.Command{
 			nonce = msg.Nonce
 		case 2:
 			arg0 := cctx.Args().Get(0)
-			f, err := address.NewFromString(arg0)
+			f, err := parseAddress(arg0)
 			if err != nil {
 				return err
 			}
@@ -176,6 +176,17 @@ var msgReplaceCmd = &cli.Command{
 			}
 		}
 
+		printMessage(ctx, api, &msg)
+		if dryRun {
+			return simulateMessage(ctx, api, &msg)
+		}
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+
 		mb, err := msg.ToStorageBlock()
 		if err != nil {
 			fmt.Printf("序列化消息失败， err:%v", err)
@@ -190,11 +201,13 @@ var msgReplaceCmd = &cli.Command{
 		}
 
 		// 推送消息
-		cid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: msg, Signature: *sb})
+		smsg := &types.SignedMessage{Message: msg, Signature: *sb}
+		cid, err := api.MpoolPush(ctx, smsg)
 		if err != nil {
 			fmt.Printf("推送消息上链失败，err:%v\n", err)
 			return err
 		}
+		recordMessage(cctx, smsg, found.Cid())
 
 		fmt.Println("new message cid: ", cid)
 		return nil
@@ -15,9 +15,9 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
 	"github.com/filecoin-project/lotus/api/v1api"
 	"github.com/filecoin-project/lotus/blockstore"
-	"github.com/filecoin-project/lotus/build"
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/actors/adt"
 	"github.com/filecoin-project/lotus/chain/actors/builtin"
@@ -89,6 +89,7 @@ var msigCmd = &cli.Command{
 		msigVestedCmd,
 		msigSwapApproveCmd,
 		msigSwapProposeCmd,
+		msigBundleCmd,
 	},
 }
 
@@ -115,13 +116,16 @@ var msigCreateCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the create message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() < 1 {
 			return ShowHelp(cctx, fmt.Errorf("multisigs must have at least one signer"))
@@ -132,7 +136,7 @@ var msigCreateCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		api, acloser, err := lcli.GetFullNodeAPIV1(cctx)
+		api, acloser, err := getFullNodeAPIV1(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -143,7 +147,7 @@ var msigCreateCmd = &cli.Command{
 
 		var addrs []address.Address
 		for _, a := range cctx.Args().Slice() {
-			addr, err := address.NewFromString(a)
+			addr, err := parseAddress(a)
 			if err != nil {
 				fmt.Println(err)
 				return err
@@ -162,7 +166,7 @@ var msigCreateCmd = &cli.Command{
 
 			sendAddr = defaddr
 		} else {
-			addr, err := address.NewFromString(send)
+			addr, err := parseAddress(send)
 			if err != nil {
 				fmt.Println(err)
 				return err
@@ -201,16 +205,13 @@ var msigCreateCmd = &cli.Command{
 			return err
 		}
 
-		// wait for it to get mined into a block
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
-			fmt.Println(err)
-			return err
+		if msgCid == cid.Undef {
+			return nil
 		}
 
-		// check it executed successfully
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Fprintln(cctx.App.Writer, "actor creation failed!")
+		// wait for it to get mined into a block
+		wait, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid)
+		if err != nil {
 			return err
 		}
 
@@ -236,13 +237,16 @@ var msigProposeCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() < 3 {
 			fmt.Printf("must pass at least multisig address, destination, and value")
@@ -257,7 +261,7 @@ var msigProposeCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -267,13 +271,13 @@ var msigProposeCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			fmt.Println(err)
 			return err
 		}
 
-		dest, err := address.NewFromString(cctx.Args().Get(1))
+		dest, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -305,7 +309,7 @@ var msigProposeCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				fmt.Println(err)
 				return err
@@ -343,19 +347,17 @@ var msigProposeCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("send proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
+		wait, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid)
 		if err != nil {
-			fmt.Println(err)
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Printf("proposal returned exit %d", wait.Receipt.ExitCode)
-			return fmt.Errorf("proposal returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		var retval msig2.ProposeReturn
 		if err := retval.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
 			fmt.Printf("failed to unmarshal propose return value: %s", err)
@@ -382,13 +384,16 @@ var msigCancelCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the cancel message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.NArg() < 2 {
 			return ShowHelp(cctx, fmt.Errorf("must pass at least multisig address and message ID"))
@@ -402,7 +407,7 @@ var msigCancelCmd = &cli.Command{
 			return ShowHelp(cctx, fmt.Errorf("usage: msig cancel <msig addr> <message ID> <desination> <value> [ <method> <params> ]"))
 		}
 
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -411,7 +416,7 @@ var msigCancelCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -423,7 +428,7 @@ var msigCancelCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -448,9 +453,13 @@ var msigCancelCmd = &cli.Command{
 				return err
 			}
 
+			if sm == cid.Undef {
+				return nil
+			}
+
 			msgCid = sm
 		} else {
-			dest, err := address.NewFromString(cctx.Args().Get(2))
+			dest, err := parseAddress(cctx.Args().Get(2))
 			if err != nil {
 				return err
 			}
@@ -486,20 +495,19 @@ var msigCancelCmd = &cli.Command{
 				return err
 			}
 
+			if sm == cid.Undef {
+				return nil
+			}
+
 			msgCid = sm
 		}
 
 		fmt.Println("sent cancel in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode.IsError() {
-			return fmt.Errorf("cancel returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -534,7 +542,7 @@ var msigInspectCmd = &cli.Command{
 		//	return fmt.Errorf("密码错误")
 		//}
 
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -544,7 +552,7 @@ var msigInspectCmd = &cli.Command{
 
 		store := adt.WrapStore(ctx, cbor.NewCborStore(blockstore.NewAPIBlockstore(api)))
 
-		maddr, err := address.NewFromString(cctx.Args().First())
+		maddr, err := parseAddress(cctx.Args().First())
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -713,13 +721,16 @@ var msigProposeChangeOwnerCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		//if cctx.Args().Len() < 3 {
 		//	return ShowHelp(cctx, fmt.Errorf("must pass at least multisig address, destination, and value"))
@@ -733,7 +744,7 @@ var msigProposeChangeOwnerCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -742,12 +753,12 @@ var msigProposeChangeOwnerCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		maddr, err := address.NewFromString(cctx.Args().Get(1))
+		maddr, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
@@ -764,7 +775,7 @@ var msigProposeChangeOwnerCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -796,17 +807,16 @@ var msigProposeChangeOwnerCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("send proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("proposal returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		//var retval msig2.ProposeReturn
 		//if err := retval.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return)); err != nil {
 		//	return fmt.Errorf("failed to unmarshal propose return value: %w", err)
@@ -832,20 +842,23 @@ var msigProposeChangeWorkerCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
 
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -855,18 +868,18 @@ var msigProposeChangeWorkerCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		//msig, err := address.NewFromString(cctx.Args().Get(0))
+		//msig, err := parseAddress(cctx.Args().Get(0))
 		//if err != nil {
 		//	return err
 		//}
 
-		maddr, err := address.NewFromString(cctx.Args().Get(0))
+		maddr, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			fmt.Println(err)
 			return err
 		}
 
-		worker, err := address.NewFromString(cctx.Args().Get(1))
+		worker, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -909,7 +922,7 @@ var msigProposeChangeWorkerCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				fmt.Println(err)
 				return err
@@ -947,18 +960,15 @@ var msigProposeChangeWorkerCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("send proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
-			fmt.Println(err)
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
-
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Printf("proposal returned exit %d", wait.Receipt.ExitCode)
-			return fmt.Errorf("proposal returned exit %d", wait.Receipt.ExitCode)
-		}
 		return nil
 	},
 }
@@ -972,19 +982,23 @@ var msigProposeControlSetCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -993,7 +1007,7 @@ var msigProposeControlSetCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		maddr, err := address.NewFromString(cctx.Args().Get(0))
+		maddr, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -1021,7 +1035,7 @@ var msigProposeControlSetCmd = &cli.Command{
 			if i == 0 {
 				continue
 			}
-			a, err := address.NewFromString(as)
+			a, err := parseAddress(as)
 			if err != nil {
 				return xerrors.Errorf("parsing address %d: %w", i, err)
 			}
@@ -1061,7 +1075,7 @@ var msigProposeControlSetCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1093,6 +1107,10 @@ var msigProposeControlSetCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("Message CID:", msgCid)
 
 		return nil
@@ -1107,19 +1125,22 @@ var msigProposeWithdrawCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if !passwdValid {
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -1129,7 +1150,7 @@ var msigProposeWithdrawCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		maddr, err := address.NewFromString(cctx.Args().Get(0))
+		maddr, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -1164,7 +1185,7 @@ var msigProposeWithdrawCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				fmt.Println(err)
 				return err
@@ -1208,19 +1229,16 @@ var msigProposeWithdrawCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("send proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
-			fmt.Println(err)
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			fmt.Println(fmt.Errorf("proposal returned exit %d", wait.Receipt.ExitCode))
-			return fmt.Errorf("proposal returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -1238,13 +1256,16 @@ var msigRemoveProposeCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 2 {
 			return ShowHelp(cctx, fmt.Errorf("must pass multisig address and signer address"))
@@ -1255,7 +1276,7 @@ var msigRemoveProposeCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1264,19 +1285,19 @@ var msigRemoveProposeCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		addr, err := address.NewFromString(cctx.Args().Get(1))
+		addr, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1299,17 +1320,17 @@ var msigRemoveProposeCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("sent remove proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
+		wait, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid)
 		if err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("add proposal returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		var ret multisig.ProposeReturn
 		err = ret.UnmarshalCBOR(bytes.NewReader(wait.Receipt.Return))
 		if err != nil {
@@ -1329,13 +1350,16 @@ var msigApproveCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the approve message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() < 2 {
 			return ShowHelp(cctx, fmt.Errorf("must pass at least multisig address and message ID"))
@@ -1354,7 +1378,7 @@ var msigApproveCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1363,7 +1387,7 @@ var msigApproveCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -1375,7 +1399,7 @@ var msigApproveCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1400,9 +1424,13 @@ var msigApproveCmd = &cli.Command{
 				return err
 			}
 
+			if mcid == cid.Undef {
+				return nil
+			}
+
 			msgCid = mcid
 		} else {
-			proposer, err := address.NewFromString(cctx.Args().Get(2))
+			proposer, err := parseAddress(cctx.Args().Get(2))
 			if err != nil {
 				return err
 			}
@@ -1414,7 +1442,7 @@ var msigApproveCmd = &cli.Command{
 				}
 			}
 
-			dest, err := address.NewFromString(cctx.Args().Get(3))
+			dest, err := parseAddress(cctx.Args().Get(3))
 			if err != nil {
 				return err
 			}
@@ -1450,20 +1478,19 @@ var msigApproveCmd = &cli.Command{
 				return err
 			}
 
+			if smcid == cid.Undef {
+				return nil
+			}
+
 			msgCid = smcid
 		}
 
 		fmt.Println("sent approval in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("approve returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -1480,13 +1507,16 @@ var msigAddProposeCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the propose message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 2 {
 			return ShowHelp(cctx, fmt.Errorf("must pass multisig address and signer address"))
@@ -1495,7 +1525,7 @@ var msigAddProposeCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1504,19 +1534,19 @@ var msigAddProposeCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		addr, err := address.NewFromString(cctx.Args().Get(1))
+		addr, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1539,17 +1569,16 @@ var msigAddProposeCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Fprintln(cctx.App.Writer, "sent add proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("add proposal returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -1562,13 +1591,16 @@ var msigAddApproveCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the approve message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 5 {
 			return ShowHelp(cctx, fmt.Errorf("must pass multisig address, proposer address, transaction id, new signer address, whether to increase threshold"))
@@ -1577,7 +1609,7 @@ var msigAddApproveCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1586,12 +1618,12 @@ var msigAddApproveCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		prop, err := address.NewFromString(cctx.Args().Get(1))
+		prop, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
@@ -1601,7 +1633,7 @@ var msigAddApproveCmd = &cli.Command{
 			return err
 		}
 
-		newAdd, err := address.NewFromString(cctx.Args().Get(3))
+		newAdd, err := parseAddress(cctx.Args().Get(3))
 		if err != nil {
 			return err
 		}
@@ -1613,7 +1645,7 @@ var msigAddApproveCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1636,17 +1668,16 @@ var msigAddApproveCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("sent add approval in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("add approval returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -1660,13 +1691,16 @@ var msigAddCancelCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the approve message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 4 {
 			return ShowHelp(cctx, fmt.Errorf("must pass multisig address, transaction id, new signer address, whether to increase threshold"))
@@ -1675,7 +1709,7 @@ var msigAddCancelCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1684,7 +1718,7 @@ var msigAddCancelCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -1694,7 +1728,7 @@ var msigAddCancelCmd = &cli.Command{
 			return err
 		}
 
-		newAdd, err := address.NewFromString(cctx.Args().Get(2))
+		newAdd, err := parseAddress(cctx.Args().Get(2))
 		if err != nil {
 			return err
 		}
@@ -1706,7 +1740,7 @@ var msigAddCancelCmd = &cli.Command{
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1729,17 +1763,16 @@ var msigAddCancelCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("sent add cancellation in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("add cancellation returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -1773,14 +1806,14 @@ var msigVestedCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		api, closer, err := getFullNodeAPI(cctx)
 		if err != nil {
 			return err
 		}
 		defer closer()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
@@ -1823,13 +1856,16 @@ var msigSwapApproveCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the approve message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 5 {
 			return ShowHelp(cctx, fmt.Errorf("must pass multisig address, proposer address, transaction id, old signer address, new signer address"))
@@ -1839,7 +1875,7 @@ var msigSwapApproveCmd = &cli.Command{
 			return fmt.Errorf("密码错误")
 		}
 
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1848,12 +1884,12 @@ var msigSwapApproveCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		prop, err := address.NewFromString(cctx.Args().Get(1))
+		prop, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
@@ -1863,19 +1899,19 @@ var msigSwapApproveCmd = &cli.Command{
 			return err
 		}
 
-		oldAdd, err := address.NewFromString(cctx.Args().Get(3))
+		oldAdd, err := parseAddress(cctx.Args().Get(3))
 		if err != nil {
 			return err
 		}
 
-		newAdd, err := address.NewFromString(cctx.Args().Get(4))
+		newAdd, err := parseAddress(cctx.Args().Get(4))
 		if err != nil {
 			return err
 		}
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1898,17 +1934,16 @@ var msigSwapApproveCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("sent swap approval in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("swap approval returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
@@ -1922,13 +1957,16 @@ var msigSwapProposeCmd = &cli.Command{
 			Name:  "from",
 			Usage: "account to send the approve message from",
 		},
+		unsignedOutFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
 	},
-	Before: func(context *cli.Context) error {
-		if err := _init(); err != nil {
-			passwdValid = false
-		}
-		return nil
-	},
+	Before: messageBefore,
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 3 {
 			return ShowHelp(cctx, fmt.Errorf("must pass multisig address, old signer address, new signer address"))
@@ -1937,7 +1975,7 @@ var msigSwapProposeCmd = &cli.Command{
 			fmt.Println("密码错误.")
 			return fmt.Errorf("密码错误")
 		}
-		srv, err := lcli.GetFullNodeServices(cctx)
+		srv, err := getFullNodeServices(cctx)
 		if err != nil {
 			return err
 		}
@@ -1946,24 +1984,24 @@ var msigSwapProposeCmd = &cli.Command{
 		api := srv.FullNodeAPI()
 		ctx := lcli.ReqContext(cctx)
 
-		msig, err := address.NewFromString(cctx.Args().Get(0))
+		msig, err := parseAddress(cctx.Args().Get(0))
 		if err != nil {
 			return err
 		}
 
-		oldAdd, err := address.NewFromString(cctx.Args().Get(1))
+		oldAdd, err := parseAddress(cctx.Args().Get(1))
 		if err != nil {
 			return err
 		}
 
-		newAdd, err := address.NewFromString(cctx.Args().Get(2))
+		newAdd, err := parseAddress(cctx.Args().Get(2))
 		if err != nil {
 			return err
 		}
 
 		var from address.Address
 		if cctx.IsSet("from") {
-			f, err := address.NewFromString(cctx.String("from"))
+			f, err := parseAddress(cctx.String("from"))
 			if err != nil {
 				return err
 			}
@@ -1986,61 +2024,20 @@ var msigSwapProposeCmd = &cli.Command{
 			return err
 		}
 
+		if msgCid == cid.Undef {
+			return nil
+		}
+
 		fmt.Println("sent swap proposal in message: ", msgCid)
 
-		wait, err := api.StateWaitMsg(ctx, msgCid, uint64(cctx.Int("confidence")), build.Finality, true)
-		if err != nil {
+		if _, err := waitMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, msgCid); err != nil {
 			return err
 		}
 
-		if wait.Receipt.ExitCode != 0 {
-			return fmt.Errorf("swap proposal returned exit %d", wait.Receipt.ExitCode)
-		}
-
 		return nil
 	},
 }
 
 func InteractiveSend(ctx context.Context, cctx *cli.Context, api v1api.FullNode, proto *api.MessagePrototype) (cid.Cid, error) {
-	// 获取nonce
-	if !proto.ValidNonce {
-		a, err := api.StateGetActor(ctx, proto.Message.From, types.EmptyTSK)
-		if err != nil {
-			fmt.Printf("读取获取owner地址的nonce失败，err:%v\n", err)
-			return cid.Cid{}, err
-		}
-		proto.Message.Nonce = a.Nonce
-	}
-
-	msg, err := api.GasEstimateMessageGas(ctx, &proto.Message, nil, types.EmptyTSK)
-	if err != nil {
-		fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
-		return cid.Cid{}, xerrors.Errorf("GasEstimateMessageGas error: %w", err)
-	}
-
-	fmt.Printf("\n%+v\n", msg)
-
-	mb, err := msg.ToStorageBlock()
-	if err != nil {
-		fmt.Printf("序列化消息失败， err:%v", err)
-		return cid.Cid{}, xerrors.Errorf("serializing message: %w", err)
-	}
-
-	// 签名
-	sb, err := signMessage(mb.Cid().Bytes(), msg.From)
-	if err != nil {
-		fmt.Printf("签名失败， err:%v\n", err)
-		return cid.Cid{}, xerrors.Errorf("签名失败: %w", err)
-	}
-
-	// 推送消息
-	msgCid, err := api.MpoolPush(ctx, &types.SignedMessage{Message: *msg, Signature: *sb})
-	if err != nil {
-		fmt.Printf("推送消息上链失败，err:%v\n", err)
-		return cid.Cid{}, err
-	}
-
-	fmt.Println("Message CID:", msgCid.String())
-
-	return msgCid, nil
+	return sendMessage(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, &proto.Message, proto.ValidNonce)
 }
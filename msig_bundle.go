@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	mbuildin "github.com/filecoin-project/go-state-types/builtin"
+	msig15 "github.com/filecoin-project/go-state-types/builtin/v15/multisig"
+	"github.com/filecoin-project/go-state-types/crypto"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
+	"github.com/filecoin-project/lotus/chain/consensus"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/xerrors"
+)
+
+// msigApproval 一个签名人的approve消息，sign时填入签名
+type msigApproval struct {
+	Signer    address.Address
+	Message   *types.Message
+	Signature *crypto.Signature `json:",omitempty"`
+}
+
+// msigBundle 多签提案的部分签名文件，由协调人创建，各签名人离线追加签名，最后由协调人统一推送
+type msigBundle struct {
+	Version      int
+	Network      string
+	NetworkName  string
+	CreatedAt    time.Time
+	Msig         address.Address
+	TxnID        uint64
+	ProposalHash string
+	Proposer     address.Address
+	To           address.Address
+	Value        abi.TokenAmount
+	Method       abi.MethodNum
+	Params       []byte
+	Summary      []string
+	Threshold    uint64
+	Approved     []address.Address
+	Approvals    []*msigApproval
+}
+
+// proposalHash 计算提案hash，与链上multisig actor的计算方式一致
+func (b *msigBundle) proposalHash() ([]byte, error) {
+	data, err := (&multisig.ProposalHashData{
+		Requester: b.Proposer,
+		To:        b.To,
+		Value:     b.Value,
+		Method:    b.Method,
+		Params:    b.Params,
+	}).Serialize()
+	if err != nil {
+		return nil, err
+	}
+	h := blake2b.Sum256(data)
+	return h[:], nil
+}
+
+// check 校验文件中的提案hash以及每个approve消息，离线签名前必须确认签的是这个提案
+func (b *msigBundle) check() error {
+	hash, err := b.proposalHash()
+	if err != nil {
+		return xerrors.Errorf("计算提案hash失败: %w", err)
+	}
+	if hex.EncodeToString(hash) != b.ProposalHash {
+		return xerrors.Errorf("提案hash不一致，文件可能被修改过")
+	}
+
+	for _, a := range b.Approvals {
+		if a.Message.To != b.Msig || a.Message.Method != mbuildin.MethodsMultisig.Approve || !a.Message.Value.IsZero() {
+			return xerrors.Errorf("签名人 %s 的消息不是对 %s 的approve消息", a.Signer, b.Msig)
+		}
+
+		var params msig15.TxnIDParams
+		if err := params.UnmarshalCBOR(bytes.NewReader(a.Message.Params)); err != nil {
+			return xerrors.Errorf("解析签名人 %s 的approve参数失败: %w", a.Signer, err)
+		}
+		if uint64(params.ID) != b.TxnID || !bytes.Equal(params.ProposalHash, hash) {
+			return xerrors.Errorf("签名人 %s 的approve消息与提案不一致", a.Signer)
+		}
+	}
+	return nil
+}
+
+// missing 还需要多少个签名才能达到阈值
+func (b *msigBundle) missing() int {
+	n := int(b.Threshold) - len(b.Approved)
+	for _, a := range b.Approvals {
+		if a.Signature != nil {
+			n--
+		}
+	}
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+func (b *msigBundle) print() {
+	fmt.Printf("网络: %s (%s)\n", b.Network, b.NetworkName)
+	fmt.Printf("多签地址: %s\n", b.Msig)
+	fmt.Printf("提案ID: %d\n", b.TxnID)
+	fmt.Printf("提案hash: %s\n", b.ProposalHash)
+	fmt.Printf("提案人: %s\n", b.Proposer)
+	for _, s := range b.Summary {
+		fmt.Println(s)
+	}
+	fmt.Printf("阈值: %d，已在链上批准: %v\n", b.Threshold, b.Approved)
+	for _, a := range b.Approvals {
+		state := "未签名"
+		if a.Signature != nil {
+			state = "已签名"
+		}
+		fmt.Printf("  %s\t%s\tnonce: %d\t%s\n", a.Signer, a.Message.From, a.Message.Nonce, state)
+	}
+	fmt.Printf("还缺少 %d 个签名\n", b.missing())
+}
+
+func writeMsigBundle(path string, b *msigBundle) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+func readMsigBundle(path string) (*msigBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var b msigBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, xerrors.Errorf("解析多签文件失败: %w", err)
+	}
+	if b.Version != bundleVersion {
+		return nil, xerrors.Errorf("不支持的多签文件版本 %d", b.Version)
+	}
+	if b.Network != activeNetwork {
+		return nil, xerrors.Errorf("多签文件是为 %s 网络构造的，当前网络为 %s", b.Network, activeNetwork)
+	}
+	if err := b.check(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+var msigBundleCmd = &cli.Command{
+	Name:  "bundle",
+	Usage: "多签提案的离线签名：create 生成待签名文件，各签名人 sign 离线签名，最后 broadcast 推送",
+	Subcommands: []*cli.Command{
+		msigBundleCreateCmd,
+		msigBundleSignCmd,
+		msigBundleStatusCmd,
+		msigBundleBroadcastCmd,
+	},
+}
+
+var msigBundleCreateCmd = &cli.Command{
+	Name:      "create",
+	Usage:     "为链上待批准的提案生成待签名文件，为每个还未批准的签名人构造approve消息",
+	ArgsUsage: "<multisigAddress> <txnId>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "out",
+			Usage:    "待签名文件",
+			Required: true,
+		},
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+	},
+	Before: func(context *cli.Context) error {
+		if err := _initDb(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 2 {
+			return ShowHelp(cctx, fmt.Errorf("must pass multisig address and transaction ID"))
+		}
+
+		api, closer, err := getFullNodeAPIV1(cctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		msig, err := parseAddress(cctx.Args().Get(0))
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		txid, err := strconv.ParseUint(cctx.Args().Get(1), 10, 64)
+		if err != nil {
+			fmt.Println("解析提案ID失败,", err)
+			return err
+		}
+
+		pending, err := api.MsigGetPending(ctx, msig, types.EmptyTSK)
+		if err != nil {
+			fmt.Println("读取多签待批准提案失败,", err)
+			return err
+		}
+
+		var txn *lapi.MsigTransaction
+		for _, p := range pending {
+			if uint64(p.ID) == txid {
+				txn = p
+			}
+		}
+		if txn == nil || len(txn.Approved) == 0 {
+			fmt.Printf("多签 %s 没有待批准的提案 %d\n", msig, txid)
+			return xerrors.Errorf("pending transaction %d not found", txid)
+		}
+
+		name, err := api.StateNetworkName(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		store := adt.WrapStore(ctx, cbor.NewCborStore(blockstore.NewAPIBlockstore(api)))
+		act, err := api.StateGetActor(ctx, msig, types.EmptyTSK)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		mstate, err := multisig.Load(store, act)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		threshold, err := mstate.Threshold()
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		signerIDs, err := mstate.Signers()
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		b := &msigBundle{
+			Version:     bundleVersion,
+			Network:     activeNetwork,
+			NetworkName: string(name),
+			CreatedAt:   time.Now(),
+			Msig:        msig,
+			TxnID:       txid,
+			Proposer:    txn.Approved[0],
+			To:          txn.To,
+			Value:       txn.Value,
+			Method:      txn.Method,
+			Params:      txn.Params,
+			Threshold:   threshold,
+			Approved:    txn.Approved,
+		}
+
+		hash, err := b.proposalHash()
+		if err != nil {
+			fmt.Println("计算提案hash失败,", err)
+			return err
+		}
+		b.ProposalHash = hex.EncodeToString(hash)
+		b.Summary = describeCall(ctx, &v0api.WrapperV1Full{FullNode: api}, txn.To, txn.Value, txn.Method, txn.Params)
+
+		approved := map[address.Address]bool{}
+		for _, a := range txn.Approved {
+			approved[a] = true
+		}
+
+		for _, s := range signerIDs {
+			if approved[s] {
+				continue
+			}
+
+			key, err := api.StateAccountKey(ctx, s, types.EmptyTSK)
+			if err != nil {
+				fmt.Printf("签名人 %s 不是普通账户，无法离线签名，跳过: %v\n", s, err)
+				continue
+			}
+
+			proto, err := api.MsigApproveTxnHash(ctx, msig, txid, b.Proposer, txn.To, txn.Value, key, uint64(txn.Method), txn.Params)
+			if err != nil {
+				fmt.Printf("构造签名人 %s 的approve消息失败: %v\n", s, err)
+				return err
+			}
+
+			sa, err := api.StateGetActor(ctx, key, types.EmptyTSK)
+			if err != nil {
+				fmt.Printf("读取获取%s地址的nonce失败，err:%v\n", key, err)
+				return err
+			}
+			proto.Message.Nonce = sa.Nonce
+
+			msg, err := estimateMessageGas(ctx, cctx, &v0api.WrapperV1Full{FullNode: api}, &proto.Message)
+			if err != nil {
+				fmt.Printf("评估签名人 %s 的approve消息gas失败， err:%v\n", s, err)
+				return err
+			}
+
+			b.Approvals = append(b.Approvals, &msigApproval{Signer: s, Message: msg})
+		}
+
+		if err := writeMsigBundle(cctx.String("out"), b); err != nil {
+			fmt.Printf("写入多签文件失败，err:%v\n", err)
+			return err
+		}
+
+		b.print()
+		fmt.Printf("待签名文件已写入 %s，请交给各签名人执行 msig bundle sign\n", cctx.String("out"))
+		return nil
+	},
+}
+
+var msigBundleSignCmd = &cli.Command{
+	Name:      "sign",
+	Usage:     "离线对多签文件中属于本钱包的approve消息签名",
+	ArgsUsage: "<bundle file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "只签这个地址的approve消息，默认签所有本钱包管理的地址",
+		},
+		&cli.StringFlag{
+			Name:  "out",
+			Usage: "签名后的文件，默认覆盖原文件",
+		},
+	},
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定多签文件")
+			return fmt.Errorf("必须指定多签文件")
+		}
+
+		b, err := readMsigBundle(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		var from address.Address
+		if cctx.IsSet("from") {
+			from, err = parseAddress(cctx.String("from"))
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+		}
+
+		b.print()
+
+		signed := 0
+		for _, a := range b.Approvals {
+			if a.Signature != nil {
+				continue
+			}
+			if from != address.Undef && a.Message.From != from {
+				continue
+			}
+			if _, ok := signers.Lookup(a.Message.From); !ok {
+				continue
+			}
+
+			sb, err := signMessage(a.Message.Cid().Bytes(), a.Message.From)
+			if err != nil {
+				fmt.Printf("签名失败， err:%v\n", err)
+				return xerrors.Errorf("签名失败: %w", err)
+			}
+			a.Signature = sb
+			signed++
+			fmt.Printf("已签名: %s (%s)\n", a.Message.From, a.Signer)
+		}
+
+		if signed == 0 {
+			fmt.Println("多签文件中没有需要本钱包签名的approve消息")
+			return fmt.Errorf("没有需要签名的消息")
+		}
+
+		out := cctx.String("out")
+		if out == "" {
+			out = cctx.Args().First()
+		}
+		if err := writeMsigBundle(out, b); err != nil {
+			fmt.Printf("写入多签文件(%s)失败，err:%v\n", out, err)
+			return err
+		}
+
+		fmt.Printf("签名完成，已写入 %s，还缺少 %d 个签名\n", out, b.missing())
+		return nil
+	},
+}
+
+var msigBundleStatusCmd = &cli.Command{
+	Name:      "status",
+	Usage:     "查看多签文件的签名情况",
+	ArgsUsage: "<bundle file>",
+	Before: func(cctx *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定多签文件")
+			return fmt.Errorf("必须指定多签文件")
+		}
+
+		b, err := readMsigBundle(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		b.print()
+		return nil
+	},
+}
+
+var msigBundleBroadcastCmd = &cli.Command{
+	Name:      "broadcast",
+	Usage:     "按nonce顺序推送多签文件中已签名的approve消息，达到阈值后不再推送",
+	ArgsUsage: "<bundle file>",
+	Flags: []cli.Flag{
+		yesFlag,
+	},
+	Before: func(cctx *cli.Context) error {
+		return _initDb()
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定多签文件")
+			return fmt.Errorf("必须指定多签文件")
+		}
+
+		b, err := readMsigBundle(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		api, closer, err := getFullNodeAPIV1(cctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		name, err := api.StateNetworkName(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if string(name) != b.NetworkName {
+			fmt.Printf("多签文件是为 %s 构造的，节点所在网络为 %s\n", b.NetworkName, name)
+			return xerrors.Errorf("network mismatch: bundle %s, node %s", b.NetworkName, name)
+		}
+
+		// 以链上的最新状态为准，提案可能已经执行或者有人已经在线批准
+		pending, err := api.MsigGetPending(ctx, b.Msig, types.EmptyTSK)
+		if err != nil {
+			fmt.Println("读取多签待批准提案失败,", err)
+			return err
+		}
+		var approved []address.Address
+		found := false
+		for _, p := range pending {
+			if uint64(p.ID) == b.TxnID {
+				found = true
+				approved = p.Approved
+			}
+		}
+		if !found {
+			fmt.Printf("提案 %d 已不在待批准列表中，可能已经执行或取消\n", b.TxnID)
+			return xerrors.Errorf("pending transaction %d not found", b.TxnID)
+		}
+
+		onChain := map[address.Address]bool{}
+		for _, a := range approved {
+			onChain[a] = true
+		}
+
+		var ready []*msigApproval
+		for _, a := range b.Approvals {
+			if a.Signature == nil || onChain[a.Signer] {
+				continue
+			}
+			smsg := &types.SignedMessage{Message: *a.Message, Signature: *a.Signature}
+			if err := consensus.AuthenticateMessage(smsg, a.Message.From); err != nil {
+				fmt.Printf("签名人 %s 的签名验证失败: %v\n", a.Signer, err)
+				return err
+			}
+			ready = append(ready, a)
+		}
+
+		sort.Slice(ready, func(i, j int) bool {
+			if ready[i].Message.From != ready[j].Message.From {
+				return ready[i].Message.From.String() < ready[j].Message.From.String()
+			}
+			return ready[i].Message.Nonce < ready[j].Message.Nonce
+		})
+
+		need := int(b.Threshold) - len(approved)
+		if need > 0 && len(ready) > 0 {
+			b.print()
+			if err := confirmSend(cctx); err != nil {
+				if err == errCanceled {
+					return nil
+				}
+				return err
+			}
+		}
+		pushed := 0
+		for _, a := range ready {
+			if pushed >= need {
+				break
+			}
+
+			sa, err := api.StateGetActor(ctx, a.Message.From, types.EmptyTSK)
+			if err != nil {
+				fmt.Printf("读取获取%s地址的nonce失败，err:%v\n", a.Message.From, err)
+				return err
+			}
+			if sa.Nonce > a.Message.Nonce {
+				fmt.Printf("签名人 %s 的approve消息nonce(%d)已被使用(链上nonce %d)，需要重新create\n", a.Signer, a.Message.Nonce, sa.Nonce)
+				continue
+			}
+
+			smsg := &types.SignedMessage{Message: *a.Message, Signature: *a.Signature}
+			msgCid, err := api.MpoolPush(ctx, smsg)
+			if err != nil {
+				fmt.Printf("推送签名人 %s 的approve消息失败，err:%v\n", a.Signer, err)
+				return err
+			}
+			recordMessage(cctx, smsg, cid.Undef)
+			pushed++
+			fmt.Printf("已推送 %s 的approve消息: %s\n", a.Signer, msgCid)
+		}
+
+		missing := need - pushed
+		if missing > 0 {
+			var unsigned []address.Address
+			for _, a := range b.Approvals {
+				if a.Signature == nil && !onChain[a.Signer] {
+					unsigned = append(unsigned, a.Signer)
+				}
+			}
+			fmt.Printf("还缺少 %d 个签名才能达到阈值 %d，未签名的签名人: %v\n", missing, b.Threshold, unsigned)
+			return nil
+		}
+
+		fmt.Println("已达到多签阈值，提案将在消息上链后执行")
+		return nil
+	},
+}
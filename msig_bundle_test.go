@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	mbuildin "github.com/filecoin-project/go-state-types/builtin"
+	msig15 "github.com/filecoin-project/go-state-types/builtin/v15/multisig"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMsigBundleCheck(t *testing.T) {
+	msig, _ := address.NewIDAddress(1000)
+	proposer, _ := address.NewIDAddress(1001)
+	signer, _ := address.NewIDAddress(1002)
+
+	b := &msigBundle{
+		Msig:      msig,
+		TxnID:     3,
+		Proposer:  proposer,
+		To:        proposer,
+		Value:     types.FromFil(10),
+		Method:    0,
+		Threshold: 2,
+		Approved:  []address.Address{proposer},
+	}
+	hash, err := b.proposalHash()
+	assert.Nil(t, err)
+	b.ProposalHash = hex.EncodeToString(hash)
+
+	buf := new(bytes.Buffer)
+	assert.Nil(t, (&msig15.TxnIDParams{ID: 3, ProposalHash: hash}).MarshalCBOR(buf))
+	b.Approvals = []*msigApproval{{
+		Signer:  signer,
+		Message: &types.Message{From: signer, To: msig, Value: abi.NewTokenAmount(0), Method: mbuildin.MethodsMultisig.Approve, Params: buf.Bytes()},
+	}}
+
+	assert.Nil(t, b.check())
+	assert.Equal(t, 1, b.missing())
+
+	b.Approvals[0].Signature = &crypto.Signature{Type: crypto.SigTypeSecp256k1}
+	assert.Equal(t, 0, b.missing())
+
+	b.Value = types.FromFil(11)
+	assert.NotNil(t, b.check())
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/api/v1api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 程序配置文件，保存在程序目录下
+const configFile = "config.json"
+
+const (
+	networkMainnet   = "mainnet"
+	networkCalibnet  = "calibnet"
+	networkButterfly = "butterfly"
+	networkDevnet    = "devnet"
+)
+
+// networkNames 每个网络对应节点 StateNetworkName 返回值的前缀
+var networkNames = map[string]string{
+	networkMainnet:   "mainnet",
+	networkCalibnet:  "calibrationnet",
+	networkButterfly: "butterflynet",
+	networkDevnet:    "localnet",
+}
+
+// 当前使用的网络，由 --network、配置文件或 FULLNODE_API_INFO 决定
+var activeNetwork string
+
+// 通过全局参数 --network 指定的网络
+var networkFlag string
+
+var networkCliFlag = &cli.StringFlag{
+	Name:        "network",
+	Usage:       "指定网络: mainnet, calibnet, butterfly, devnet，不指定时读取程序目录下的 config.json",
+	Destination: &networkFlag,
+}
+
+type repoConfig struct {
+	Network string
+
+	// 默认手续费策略和按命令名(如 "send"、"msig approve")配置的手续费策略
+	Fee         *feePolicy           `json:",omitempty"`
+	CommandFees map[string]feePolicy `json:",omitempty"`
+
+	// send-batch 默认的出账账户和总账户
+	Payout *payoutConfig `json:",omitempty"`
+	// 资金策略，按顺序由 treasury run 执行
+	Treasury []*treasuryPolicy `json:",omitempty"`
+
+	// fund-control 默认的资金地址、矿工和余额下限
+	FundControl *fundControlConfig `json:",omitempty"`
+}
+
+func loadRepoConfig() (*repoConfig, error) {
+	cfg := &repoConfig{}
+	b, err := os.ReadFile(filepath.Join(getRepoPath(), configFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, xerrors.Errorf("解析配置文件 %s 失败: %w", configFile, err)
+	}
+	return cfg, nil
+}
+
+func saveRepoConfig(cfg *repoConfig) error {
+	b, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(getRepoPath(), configFile), b, 0644)
+}
+
+// selectNetwork 按 --network、配置文件、FULLNODE_API_INFO 的顺序确定当前网络
+func selectNetwork() (string, error) {
+	network := networkFlag
+	if network == "" {
+		cfg, err := loadRepoConfig()
+		if err != nil {
+			return "", err
+		}
+		network = cfg.Network
+	}
+	if network == "" {
+		network = networkMainnet
+		if strings.Contains(os.Getenv("FULLNODE_API_INFO"), "calibration") {
+			network = networkCalibnet
+		}
+	}
+
+	if _, ok := networkNames[network]; !ok {
+		return "", xerrors.Errorf("不支持的网络 %s，可选: mainnet, calibnet, butterfly, devnet", network)
+	}
+	return network, nil
+}
+
+func initNetWork() {
+	network, err := selectNetwork()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	activeNetwork = network
+	if network == networkMainnet {
+		address.CurrentNetwork = address.Mainnet
+		fmt.Println("当前运行的是主网")
+	} else {
+		address.CurrentNetwork = address.Testnet
+		fmt.Printf("当前运行的是测试网(%s)\n", network)
+	}
+}
+
+// checkNodeNetwork 检查节点所在的网络与当前选择的网络是否一致
+func checkNodeNetwork(ctx context.Context, api v0api.FullNode) error {
+	if activeNetwork == "" {
+		initNetWork()
+	}
+
+	name, err := api.StateNetworkName(ctx)
+	if err != nil {
+		return xerrors.Errorf("查询节点网络失败: %w", err)
+	}
+
+	if !strings.HasPrefix(string(name), networkNames[activeNetwork]) {
+		return xerrors.Errorf("节点所在网络(%s)与当前选择的网络(%s)不一致，请检查 --network 或 FULLNODE_API_INFO", name, activeNetwork)
+	}
+	return nil
+}
+
+// getFullNodeAPI 连接节点并检查节点网络
+func getFullNodeAPI(cctx *cli.Context) (v0api.FullNode, jsonrpc.ClientCloser, error) {
+	napi, closer, err := lcli.GetFullNodeAPI(cctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkNodeNetwork(lcli.ReqContext(cctx), napi); err != nil {
+		closer()
+		fmt.Println(err)
+		return nil, nil, err
+	}
+	return napi, closer, nil
+}
+
+func getFullNodeAPIV1(cctx *cli.Context) (v1api.FullNode, jsonrpc.ClientCloser, error) {
+	napi, closer, err := lcli.GetFullNodeAPIV1(cctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkNodeNetwork(lcli.ReqContext(cctx), &v0api.WrapperV1Full{FullNode: napi}); err != nil {
+		closer()
+		fmt.Println(err)
+		return nil, nil, err
+	}
+	return napi, closer, nil
+}
+
+func getFullNodeServices(cctx *cli.Context) (lcli.ServicesAPI, error) {
+	srv, err := lcli.GetFullNodeServices(cctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkNodeNetwork(lcli.ReqContext(cctx), &v0api.WrapperV1Full{FullNode: srv.FullNodeAPI()}); err != nil {
+		srv.Close() //nolint:errcheck
+		return nil, err
+	}
+	return srv, nil
+}
+
+// parseAddress 解析地址，并拒绝与当前网络前缀不一致的地址
+func parseAddress(s string) (address.Address, error) {
+	if activeNetwork == "" {
+		initNetWork()
+	}
+
+	prefix := address.MainnetPrefix
+	if address.CurrentNetwork == address.Testnet {
+		prefix = address.TestnetPrefix
+	}
+
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, prefix) {
+		return address.Undef, xerrors.Errorf("地址 %s 与当前网络(%s)不匹配，应该以 %s 开头", s, activeNetwork, prefix)
+	}
+	return address.NewFromString(s)
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAddressNetwork(t *testing.T) {
+	t.Setenv(repoENV, t.TempDir())
+
+	networkFlag = networkCalibnet
+	activeNetwork = ""
+	defer func() {
+		networkFlag = ""
+		activeNetwork = ""
+		address.CurrentNetwork = address.Mainnet
+	}()
+
+	a, err := parseAddress("t01000")
+	assert.Nil(t, err)
+	assert.Equal(t, "t01000", a.String())
+
+	_, err = parseAddress("f01000")
+	assert.NotNil(t, err)
+
+	networkFlag = "nonexistent"
+	_, err = selectNetwork()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 预留的nonce超过这个时间还没有推送，认为已经放弃
+const nonceReservationTTL = 10 * time.Minute
+
+// 本地消息记录中的pending消息超过这个时间不再参与nonce计算，交给 messages refresh 处理
+const nonceJournalTTL = time.Hour
+
+const nonceLockFile = "nonce.lock"
+
+var nonceLk sync.Mutex
+
+// nonceReservation 每个地址已经预留出去的下一个nonce，保存在数据库 KeyNonce 下
+type nonceReservation struct {
+	Next uint64
+	Time time.Time
+}
+
+// lockNonceFile 对程序目录下的锁文件加锁，多个进程同时发送消息时串行分配nonce
+func lockNonceFile() (func(), error) {
+	f, err := os.OpenFile(filepath.Join(getRepoPath(), nonceLockFile), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close() //nolint:errcheck
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN) //nolint:errcheck
+		f.Close()                                   //nolint:errcheck
+	}, nil
+}
+
+// reserveNonce 分配from的下一个nonce，取链上状态、节点消息池、本地消息记录和已预留nonce中的最大值
+func reserveNonce(ctx context.Context, api v0api.FullNode, from address.Address) (uint64, error) {
+	nonceLk.Lock()
+	defer nonceLk.Unlock()
+
+	unlock, err := lockNonceFile()
+	if err != nil {
+		return 0, xerrors.Errorf("锁定nonce文件失败: %w", err)
+	}
+	defer unlock()
+
+	act, err := api.StateGetActor(ctx, from, types.EmptyTSK)
+	if err != nil {
+		return 0, xerrors.Errorf("读取获取%s地址的nonce失败: %w", from, err)
+	}
+	next := act.Nonce
+
+	// MpoolGetNonce 包含节点消息池中的pending消息
+	if n, err := api.MpoolGetNonce(ctx, from); err == nil && n > next {
+		next = n
+	} else if err != nil {
+		fmt.Printf("读取获取消息池中的的nonce失败，err:%v\n", err)
+	}
+
+	if n := journalNextNonce(from, act.Nonce); n > next {
+		next = n
+	}
+
+	if localdb != nil {
+		if r, err := getNonceReservation(from); err == nil && time.Since(r.Time) < nonceReservationTTL && r.Next > next {
+			next = r.Next
+		}
+
+		if err := saveNonceReservation(from, &nonceReservation{Next: next + 1, Time: time.Now()}); err != nil {
+			return 0, xerrors.Errorf("保存预留nonce失败: %w", err)
+		}
+	}
+
+	return next, nil
+}
+
+// releaseNonce 消息推送失败时归还nonce，只有它仍是最后一个预留的nonce时才能归还
+func releaseNonce(from address.Address, nonce uint64) {
+	if localdb == nil {
+		return
+	}
+
+	nonceLk.Lock()
+	defer nonceLk.Unlock()
+
+	unlock, err := lockNonceFile()
+	if err != nil {
+		return
+	}
+	defer unlock()
+
+	r, err := getNonceReservation(from)
+	if err != nil || r.Next != nonce+1 {
+		return
+	}
+	if err := saveNonceReservation(from, &nonceReservation{Next: nonce, Time: r.Time}); err != nil {
+		fmt.Printf("归还nonce失败，err: %v\n", err)
+	}
+}
+
+// journalNextNonce 本地消息记录里还在pending的消息之后的nonce
+func journalNextNonce(from address.Address, chainNonce uint64) uint64 {
+	if localdb == nil {
+		return 0
+	}
+
+	recs, err := listMsgRecords()
+	if err != nil {
+		return 0
+	}
+
+	var next uint64
+	for _, rec := range recs {
+		if rec.From != from.String() || rec.Status != msgPending || rec.Nonce < chainNonce {
+			continue
+		}
+		if time.Since(rec.Time) > nonceJournalTTL {
+			continue
+		}
+		if rec.Nonce+1 > next {
+			next = rec.Nonce + 1
+		}
+	}
+	return next
+}
+
+func getNonceReservation(from address.Address) (*nonceReservation, error) {
+	b, err := localdb.Get(db.KeyNonce, from.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var r nonceReservation
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func saveNonceReservation(from address.Address, r *nonceReservation) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return localdb.Add(db.KeyNonce, from.String(), b)
+}
+
+// findNonceGaps 找出消息池中from的pending消息之间缺失的nonce，缺失的nonce会导致后面的消息一直无法打包
+func findNonceGaps(chainNonce uint64, pending []uint64) []uint64 {
+	sort.Slice(pending, func(i, j int) bool { return pending[i] < pending[j] })
+
+	var gaps []uint64
+	expect := chainNonce
+	for _, n := range pending {
+		if n < expect {
+			continue
+		}
+		for ; expect < n; expect++ {
+			gaps = append(gaps, expect)
+		}
+		expect = n + 1
+	}
+	return gaps
+}
+
+var nonceCmd = &cli.Command{
+	Name:  "nonce",
+	Usage: "nonce管理",
+	Subcommands: []*cli.Command{
+		nonceGapsCmd,
+	},
+}
+
+var nonceGapsCmd = &cli.Command{
+	Name:      "gaps",
+	Usage:     "检查地址在消息池中的nonce空洞，--fill 用0金额转给自己的消息填补",
+	ArgsUsage: "<address>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "fill",
+			Usage: "发送0金额转给自己的消息填补空洞",
+		},
+		yesFlag,
+	},
+	Before: func(cctx *cli.Context) error {
+		// 只检查时不需要输入密码
+		if !cctx.Bool("fill") || dryRun {
+			return _initDb()
+		}
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定地址")
+			return fmt.Errorf("必须指定地址")
+		}
+
+		from, err := parseAddress(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		act, err := api.StateGetActor(ctx, from, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取获取%s地址的nonce失败，err:%v\n", from, err)
+			return err
+		}
+
+		fromID, err := api.StateLookupID(ctx, from, types.EmptyTSK)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		mpending, err := api.MpoolPending(ctx, types.EmptyTSK)
+		if err != nil {
+			fmt.Printf("读取消息池失败，err: %v\n", err)
+			return err
+		}
+
+		var nonces []uint64
+		for _, m := range mpending {
+			if m.Message.From == from || m.Message.From == fromID {
+				nonces = append(nonces, m.Message.Nonce)
+			}
+		}
+
+		gaps := findNonceGaps(act.Nonce, nonces)
+		fmt.Printf("链上nonce: %d，消息池中pending消息: %d 条\n", act.Nonce, len(nonces))
+		if len(gaps) == 0 {
+			fmt.Println("没有nonce空洞")
+			return nil
+		}
+		fmt.Printf("nonce空洞: %v\n", gaps)
+
+		if !cctx.Bool("fill") {
+			fmt.Println("使用 --fill 发送0金额转给自己的消息填补空洞")
+			return nil
+		}
+
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		for _, n := range gaps {
+			msgCid, err := sendMessage(ctx, cctx, api, &types.Message{
+				From:  from,
+				To:    from,
+				Value: big.Zero(),
+				Nonce: n,
+			}, true)
+			if err != nil {
+				fmt.Printf("填补nonce %d 失败，err: %v\n", n, err)
+				return err
+			}
+			// 模拟执行或者取消发送
+			if msgCid == cid.Undef {
+				continue
+			}
+			fmt.Printf("已填补nonce %d: %s\n", n, msgCid)
+		}
+		return nil
+	},
+}
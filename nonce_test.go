@@ -0,0 +1,16 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindNonceGaps(t *testing.T) {
+	assert.Empty(t, findNonceGaps(5, nil))
+	assert.Empty(t, findNonceGaps(5, []uint64{5, 6, 7}))
+	assert.Equal(t, []uint64{5, 6}, findNonceGaps(5, []uint64{7}))
+	assert.Equal(t, []uint64{6, 9}, findNonceGaps(5, []uint64{10, 5, 8, 7}))
+	// 已经上链的nonce忽略
+	assert.Equal(t, []uint64{6}, findNonceGaps(5, []uint64{3, 5, 7}))
+}
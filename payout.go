@@ -0,0 +1,904 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// payoutConfig send-batch 默认的出账账户和总账户，保存在 config.json
+type payoutConfig struct {
+	From     string `json:",omitempty"`
+	Treasury string `json:",omitempty"`
+}
+
+// 转账文件格式
+const (
+	payoutFormatCSV  = "csv"  // to,amount，可以有表头
+	payoutFormatJSON = "json" // [{"to": "...", "amount": "..."}]
+	payoutFormatTSV  = "tsv"  // 旧格式: 金额\t地址
+)
+
+// 每一行的发送状态，推送之后使用消息记录的状态: pending, executed, failed, replaced, dropped
+const (
+	payoutNew       = ""
+	payoutInvalid   = "invalid"
+	payoutSkipped   = "skipped"
+	payoutError     = "error"   // 推送前出错，没有发出
+	payoutSending   = "sending" // 已经签名，不确定是否推送成功，继续时按cid检查
+	payoutSimulated = "simulated"
+	payoutResolved  = "resolved" // payouts resolve --sent 人工确认已到账
+)
+
+// 批次状态文件保存在程序目录下的 payouts 目录
+const payoutDirName = "payouts"
+
+// payoutRow 转账文件中的一行以及它的发送状态
+type payoutRow struct {
+	Line   int    `json:"line"`
+	To     string `json:"to"`
+	Amount string `json:"amount"`
+
+	Status string  `json:"status,omitempty"`
+	Nonce  uint64  `json:"nonce,omitempty"`
+	Cid    cid.Cid `json:"cid,omitempty"`
+	Error  string  `json:"error,omitempty"`
+
+	toAddr address.Address
+	amount abi.TokenAmount
+}
+
+// needSend 继续执行时需要重新发送的行
+func (row *payoutRow) needSend() bool {
+	switch row.Status {
+	case payoutNew, payoutSkipped, payoutError, msgDropped, msgFailed, msgReplaced:
+		return true
+	}
+	return false
+}
+
+func (row *payoutRow) cidString() string {
+	if row.Cid == cid.Undef {
+		return ""
+	}
+	return row.Cid.String()
+}
+
+// payoutRun 一次批量转账，每一行发送前后都会保存，中断后可以用 --resume 继续
+type payoutRun struct {
+	ID        string
+	Input     string
+	From      string
+	Treasury  string `json:",omitempty"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Rows      []*payoutRow
+}
+
+func payoutRunPath(id string) string {
+	return filepath.Join(getRepoPath(), payoutDirName, id+".json")
+}
+
+// newPayoutRun 创建批次，ID为创建时间
+func newPayoutRun(input string, from, treasury address.Address, rows []*payoutRow) (*payoutRun, error) {
+	if err := os.MkdirAll(filepath.Join(getRepoPath(), payoutDirName), 0700); err != nil {
+		return nil, err
+	}
+
+	id := time.Now().Format("20060102-150405")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(payoutRunPath(id)); os.IsNotExist(err) {
+			break
+		}
+		id = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), i)
+	}
+
+	run := &payoutRun{
+		ID:        id,
+		Input:     input,
+		From:      from.String(),
+		CreatedAt: time.Now(),
+		Rows:      rows,
+	}
+	if treasury != address.Undef {
+		run.Treasury = treasury.String()
+	}
+	return run, run.save()
+}
+
+func loadPayoutRun(id string) (*payoutRun, error) {
+	b, err := os.ReadFile(payoutRunPath(id))
+	if err != nil {
+		return nil, xerrors.Errorf("读取批次 %s 失败: %w", id, err)
+	}
+	var run payoutRun
+	if err := json.Unmarshal(b, &run); err != nil {
+		return nil, xerrors.Errorf("解析批次 %s 失败: %w", id, err)
+	}
+	return &run, nil
+}
+
+// save 先写临时文件再改名，避免中断时状态文件不完整
+func (run *payoutRun) save() error {
+	run.UpdatedAt = time.Now()
+	b, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := payoutRunPath(run.ID) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, payoutRunPath(run.ID))
+}
+
+func listPayoutRuns() ([]*payoutRun, error) {
+	files, err := filepath.Glob(filepath.Join(getRepoPath(), payoutDirName, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var runs []*payoutRun
+	for _, f := range files {
+		run, err := loadPayoutRun(strings.TrimSuffix(filepath.Base(f), ".json"))
+		if err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].CreatedAt.After(runs[j].CreatedAt) })
+	return runs, nil
+}
+
+// refresh 从链上更新已经签名的行的状态
+func (run *payoutRun) refresh(ctx context.Context, api v0api.FullNode) error {
+	pending, err := mpoolPendingCids(ctx, api)
+	if err != nil {
+		return xerrors.Errorf("读取消息池失败: %w", err)
+	}
+
+	for _, row := range run.Rows {
+		if row.Cid == cid.Undef || row.Status == msgExecuted || row.Status == payoutResolved {
+			continue
+		}
+		if err := refreshPayoutRow(ctx, api, run.From, row, pending); err != nil {
+			return err
+		}
+	}
+	return run.save()
+}
+
+func refreshPayoutRow(ctx context.Context, api v0api.FullNode, from string, row *payoutRow, pending map[cid.Cid]bool) error {
+	rec, err := getMsgRecord(row.Cid.String())
+	if err != nil {
+		// 签名后没有推送成功的消息不在消息记录中
+		rec = &msgRecord{Cid: row.Cid.String(), From: from, Nonce: row.Nonce, Status: msgPending}
+		if err := lookupMsgStatus(ctx, api, rec, pending); err != nil {
+			return err
+		}
+	} else if err := refreshMsgRecord(ctx, api, rec, pending); err != nil {
+		return err
+	}
+	row.Status = rec.Status
+
+	// 被 mpool replace 提高手续费替换的消息，金额和地址相同时认为是同一笔转账。
+	// 只有确认替换消息的地址或金额不同时才重新发送，找不到替换消息时需要人工确认，避免重复转账
+	if rec.Status == msgReplaced {
+		if rec.ReplacedBy == "" {
+			row.Status = msgUnknown
+			return nil
+		}
+		c, err := cid.Decode(rec.ReplacedBy)
+		if err != nil {
+			row.Status = msgUnknown
+			return nil
+		}
+		m, err := api.ChainGetMessage(ctx, c)
+		if err != nil {
+			row.Status = msgUnknown
+			return nil
+		}
+		if m.To.String() != row.To || m.Value.String() != row.amount.String() {
+			return nil
+		}
+		row.Cid = c
+		return refreshPayoutRow(ctx, api, from, row, pending)
+	}
+	return nil
+}
+
+func payoutFormat(path, format string) (string, error) {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			format = payoutFormatJSON
+		case ".csv":
+			format = payoutFormatCSV
+		default:
+			format = payoutFormatTSV
+		}
+	}
+
+	switch format {
+	case payoutFormatCSV, payoutFormatJSON, payoutFormatTSV:
+		return format, nil
+	}
+	return "", xerrors.Errorf("不支持的文件格式 %s，可选: csv, json, tsv", format)
+}
+
+// readPayoutFile 读取转账文件，只解析格式，地址和金额由 validatePayoutRows 检查
+func readPayoutFile(path, format string) ([]*payoutRow, error) {
+	format, err := payoutFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	if format == payoutFormatJSON {
+		var in []struct {
+			To     string `json:"to"`
+			Amount string `json:"amount"`
+		}
+		if err := json.NewDecoder(f).Decode(&in); err != nil {
+			return nil, xerrors.Errorf("解析json失败: %w", err)
+		}
+		rows := make([]*payoutRow, 0, len(in))
+		for i, r := range in {
+			rows = append(rows, &payoutRow{Line: i + 1, To: strings.TrimSpace(r.To), Amount: strings.TrimSpace(r.Amount)})
+		}
+		return rows, nil
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	toIdx, amountIdx := 0, 1
+	if format == payoutFormatTSV {
+		r.Comma = '\t'
+		toIdx, amountIdx = 1, 0
+	}
+
+	var rows []*payoutRow
+	for first := true; ; first = false {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, xerrors.Errorf("解析文件失败: %w", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		// csv的第一行可以是表头，按表头确定列的位置
+		if first && format == payoutFormatCSV && isPayoutHeader(rec) {
+			for i, col := range rec {
+				switch strings.ToLower(strings.TrimSpace(col)) {
+				case "to":
+					toIdx = i
+				case "amount":
+					amountIdx = i
+				}
+			}
+			continue
+		}
+
+		row := &payoutRow{Line: line}
+		if toIdx < len(rec) {
+			row.To = strings.TrimSpace(rec[toIdx])
+		}
+		if amountIdx < len(rec) {
+			row.Amount = strings.TrimSpace(rec[amountIdx])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func isPayoutHeader(rec []string) bool {
+	for _, col := range rec {
+		if strings.EqualFold(strings.TrimSpace(col), "amount") {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePayoutRows 发送前检查所有行，返回不合法的行数
+func validatePayoutRows(rows []*payoutRow, from address.Address) int {
+	invalid := 0
+	for _, row := range rows {
+		if err := validatePayoutRow(row, from); err != nil {
+			row.Status = payoutInvalid
+			row.Error = err.Error()
+			invalid++
+		}
+	}
+	return invalid
+}
+
+func validatePayoutRow(row *payoutRow, from address.Address) error {
+	to, err := parseAddress(row.To)
+	if err != nil {
+		return xerrors.Errorf("地址 %q 不正确: %w", row.To, err)
+	}
+	if to == from {
+		return xerrors.Errorf("转入和转出地址相同")
+	}
+
+	v, err := types.ParseFIL(row.Amount)
+	if err != nil {
+		return xerrors.Errorf("金额 %q 不正确: %w", row.Amount, err)
+	}
+	amount := abi.TokenAmount(v)
+	if !amount.GreaterThan(big.Zero()) {
+		return xerrors.Errorf("金额必须大于0")
+	}
+
+	row.toAddr = to
+	row.amount = amount
+	return nil
+}
+
+// payoutReportPath 默认的结果文件，和转账文件在同一目录，不会覆盖已有文件
+func payoutReportPath(input string) string {
+	return fmt.Sprintf("%s.%s.result.csv", input, time.Now().Format("20060102-150405"))
+}
+
+func writePayoutReport(path string, rows []*payoutRow) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"line", "to", "amount", "status", "nonce", "cid", "error"}); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		var nonce string
+		if row.Cid != cid.Undef {
+			nonce = fmt.Sprint(row.Nonce)
+		}
+		if err := w.Write([]string{fmt.Sprint(row.Line), row.To, row.Amount, row.Status, nonce, row.cidString(), row.Error}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func printPayoutSummary(rows []*payoutRow) {
+	count := map[string]int{}
+	amount := map[string]abi.TokenAmount{}
+	for _, row := range rows {
+		if _, ok := amount[row.Status]; !ok {
+			amount[row.Status] = big.Zero()
+		}
+		count[row.Status]++
+		if v, err := types.ParseFIL(row.Amount); err == nil {
+			amount[row.Status] = big.Add(amount[row.Status], abi.TokenAmount(v))
+		}
+	}
+
+	statuses := make([]string, 0, len(count))
+	for s := range count {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+
+	fmt.Printf("共 %d 笔\n", len(rows))
+	for _, s := range statuses {
+		name := s
+		if name == payoutNew {
+			name = "未发送"
+		}
+		fmt.Printf("  %-10s %d 笔，共计 %s\n", name, count[s], types.FIL(amount[s]))
+	}
+}
+
+var walletSendBatchCmd = &cli.Command{
+	Name:      "send-batch",
+	Usage:     "按文件批量转账，支持 csv(to,amount)、json 和旧的 金额\\t地址 格式，中断后可以用 --resume 继续",
+	ArgsUsage: "<payout file>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "出账账户，不指定时使用 config.json 中的 Payout.From",
+		},
+		&cli.StringFlag{
+			Name:  "treasury",
+			Usage: "总账户，出账账户余额不足时从总账户转入差额，不指定时使用 config.json 中的 Payout.Treasury",
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "文件格式: csv, json, tsv，不指定时按扩展名判断，其他扩展名按旧的 金额\\t地址 格式解析",
+		},
+		&cli.StringFlag{
+			Name:  "report",
+			Usage: "每一行发送结果的输出文件，默认为 <payout file>.<时间>.result.csv",
+		},
+		&cli.StringFlag{
+			Name:  "resume",
+			Usage: "继续执行中断的批次，跳过已经执行或等待打包的行，不需要再指定转账文件",
+		},
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		var run *payoutRun
+		var fromAddr, treasury address.Address
+		if id := cctx.String("resume"); id != "" {
+			if cctx.NArg() != 0 {
+				fmt.Println("继续执行批次时不需要指定转账文件")
+				return fmt.Errorf("继续执行批次时不需要指定转账文件")
+			}
+
+			run, err = loadPayoutRun(id)
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			if fromAddr, err = parseAddress(run.From); err != nil {
+				return err
+			}
+			if run.Treasury != "" {
+				if treasury, err = parseAddress(run.Treasury); err != nil {
+					return err
+				}
+			}
+			for _, row := range run.Rows {
+				if row.Status != payoutInvalid {
+					if err := validatePayoutRow(row, fromAddr); err != nil {
+						return xerrors.Errorf("批次 %s 第 %d 行: %w", id, row.Line, err)
+					}
+				}
+			}
+
+			// 先按链上状态确认已经签名的行，避免重复转账
+			if err := run.refresh(ctx, api); err != nil {
+				fmt.Printf("更新批次状态失败，err: %v\n", err)
+				return err
+			}
+			fmt.Printf("继续执行批次 %s (%s)\n", run.ID, run.Input)
+			printPayoutSummary(run.Rows)
+		} else {
+			if cctx.NArg() != 1 {
+				fmt.Println("必须指定转账文件")
+				return fmt.Errorf("必须指定转账文件")
+			}
+			input := cctx.Args().First()
+
+			cfg, err := loadRepoConfig()
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			if cfg.Payout == nil {
+				cfg.Payout = &payoutConfig{}
+			}
+
+			fromStr := cctx.String("from")
+			if fromStr == "" {
+				fromStr = cfg.Payout.From
+			}
+			if fromStr == "" {
+				fmt.Println("必须通过 --from 或 config.json 的 Payout.From 指定出账账户")
+				return fmt.Errorf("必须指定出账账户")
+			}
+			fromAddr, err = parseAddress(fromStr)
+			if err != nil {
+				fmt.Println("出账账户地址不正确. ", fromStr)
+				return err
+			}
+
+			treasuryStr := cctx.String("treasury")
+			if treasuryStr == "" {
+				treasuryStr = cfg.Payout.Treasury
+			}
+			if treasuryStr != "" {
+				treasury, err = parseAddress(treasuryStr)
+				if err != nil {
+					fmt.Println("总账户地址不正确. ", treasuryStr)
+					return err
+				}
+			}
+
+			rows, err := readPayoutFile(input, cctx.String("format"))
+			if err != nil {
+				fmt.Printf("读取转账文件(%s)失败. err:%v\n", input, err)
+				return err
+			}
+			if len(rows) == 0 {
+				fmt.Println("转账文件中没有数据")
+				return nil
+			}
+
+			// 所有行都检查通过才开始发送
+			if invalid := validatePayoutRows(rows, fromAddr); invalid > 0 {
+				for _, row := range rows {
+					if row.Status == payoutInvalid {
+						fmt.Printf("第 %d 行: %s\n", row.Line, row.Error)
+					} else {
+						row.Status = payoutSkipped
+					}
+				}
+				reportPath := cctx.String("report")
+				if reportPath == "" {
+					reportPath = payoutReportPath(input)
+				}
+				if err := writePayoutReport(reportPath, rows); err != nil {
+					fmt.Printf("写入结果文件(%s)失败，err: %v\n", reportPath, err)
+				}
+				fmt.Printf("转账文件有 %d 行不正确，没有发送任何转账，结果已写入 %s\n", invalid, reportPath)
+				return xerrors.Errorf("%d invalid rows", invalid)
+			}
+
+			if dryRun {
+				run = &payoutRun{Input: input, From: fromAddr.String(), Rows: rows}
+			} else {
+				run, err = newPayoutRun(input, fromAddr, treasury, rows)
+				if err != nil {
+					fmt.Printf("创建批次状态文件失败，err: %v\n", err)
+					return err
+				}
+				fmt.Printf("批次ID: %s，中断后可以执行 send-batch --resume %s 继续\n", run.ID, run.ID)
+			}
+		}
+
+		reportPath := cctx.String("report")
+		if reportPath == "" {
+			reportPath = payoutReportPath(run.Input)
+		}
+
+		var todo []*payoutRow
+		total := big.Zero()
+		for _, row := range run.Rows {
+			if !row.needSend() {
+				continue
+			}
+			fmt.Printf("%d %s -> %s %s\n", row.Line, fromAddr, row.toAddr, types.FIL(row.amount))
+			todo = append(todo, row)
+			total = big.Add(total, row.amount)
+		}
+		if len(todo) == 0 {
+			fmt.Println("没有需要发送的转账")
+			printPayoutSummary(run.Rows)
+			return nil
+		}
+
+		balance, err := api.WalletBalance(ctx, fromAddr)
+		if err != nil {
+			fmt.Println(fromAddr, " get balance failed. err: ", err)
+			return err
+		}
+
+		// 转账手续费,按照每笔的最高手续费计算
+		feePerMsg, err := estimateTransferFee(ctx, cctx, api, fromAddr, todo[0].toAddr)
+		if err != nil {
+			fmt.Printf("评估转账手续费失败，err: %v\n", err)
+			return err
+		}
+		fees := big.Mul(feePerMsg, big.NewInt(int64(len(todo))))
+		need := big.Add(total, fees)
+
+		fmt.Printf("\n出账账户 %s%s 余额： %s\n", fromAddr, addressLabel(fromAddr), types.FIL(balance))
+		fmt.Printf("需要转账 %d 笔， 共计： %s\n", len(todo), types.FIL(total))
+		fmt.Printf("最高手续费: 每笔 %s，共计 %s\n\n", types.FIL(feePerMsg), types.FIL(fees))
+
+		if dryRun {
+			if balance.LessThan(need) {
+				fmt.Printf("出账账户余额不足，还差 %s\n", types.FIL(big.Sub(need, balance)))
+			}
+			for _, row := range todo {
+				fmt.Printf("%d ./firefly-wallet send --from %s --to %s --amount %s\n", row.Line, fromAddr, row.toAddr, row.Amount)
+				row.Status = payoutSimulated
+				if _, err := send(cctx, api, fromAddr, row.To, row.Amount, nil); err != nil {
+					row.Status = msgFailed
+					row.Error = err.Error()
+				}
+			}
+			if err := writePayoutReport(reportPath, run.Rows); err != nil {
+				fmt.Printf("写入结果文件(%s)失败，err: %v\n", reportPath, err)
+				return err
+			}
+			printPayoutSummary(run.Rows)
+			return nil
+		}
+
+		if balance.LessThan(need) {
+			if treasury == address.Undef {
+				fmt.Printf("出账账户余额不足，还差 %s，请归集资金或通过 --treasury 指定总账户\n", types.FIL(big.Sub(need, balance)))
+				return xerrors.Errorf("insufficient balance")
+			}
+
+			treasuryBalance, err := api.WalletBalance(ctx, treasury)
+			if err != nil {
+				fmt.Println(treasury, " get balance failed. err: ", err)
+				return err
+			}
+			fmt.Printf("总账户 %s%s 余额： %s\n", treasury, addressLabel(treasury), types.FIL(treasuryBalance))
+			topUp := big.Sub(need, balance)
+			if treasuryBalance.LessThan(big.Add(topUp, feePerMsg)) {
+				fmt.Printf("总账户余额不足以转入 %s，请归集资金再来！\n", types.FIL(topUp))
+				return xerrors.Errorf("insufficient treasury balance")
+			}
+
+			fmt.Printf("出账账户余额不足，从总账户转入 %s 后再转账\n", types.FIL(topUp))
+			fmt.Printf("./firefly-wallet send --from %s --to %s --amount %s\n", treasury, fromAddr, types.FIL(topUp).Unitless())
+		}
+
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+
+		if balance.LessThan(need) {
+			topUp := big.Sub(need, balance)
+			topUpCid, err := send(cctx, api, treasury, fromAddr.String(), types.FIL(topUp).Unitless(), nil)
+			if err != nil {
+				fmt.Println("从总账户转入失败: \n", err)
+				return err
+			}
+			fmt.Println(topUpCid.String())
+			if _, err := waitMessage(ctx, cctx, api, topUpCid); err != nil {
+				return err
+			}
+		}
+
+		for i, row := range todo {
+			fmt.Printf("%d ./firefly-wallet send --from %s --to %s --amount %s ", row.Line, fromAddr, row.toAddr, row.Amount)
+
+			row.Status, row.Error = payoutNew, ""
+			msgCid, err := send(cctx, api, fromAddr, row.To, row.Amount, func(smsg *types.SignedMessage) error {
+				// 推送前先记录签名后的消息，推送结果不确定时继续执行可以按cid检查
+				row.Status = payoutSending
+				row.Nonce = smsg.Message.Nonce
+				row.Cid = smsg.Cid()
+				return run.save()
+			})
+			if err != nil {
+				fmt.Println("transfor err: \n", err)
+				if row.Status != payoutSending {
+					row.Status = payoutError
+				}
+				row.Error = err.Error()
+				// 后面的转账不再发送，避免nonce空洞
+				for _, r := range todo[i+1:] {
+					r.Status = payoutSkipped
+				}
+				if err := run.save(); err != nil {
+					fmt.Printf("保存批次状态失败，err: %v\n", err)
+				}
+				break
+			}
+			fmt.Println(msgCid.String())
+			row.Status = msgPending
+			row.Cid = msgCid
+			if err := run.save(); err != nil {
+				fmt.Printf("保存批次状态失败，err: %v\n", err)
+				return err
+			}
+		}
+
+		if err := writePayoutReport(reportPath, run.Rows); err != nil {
+			fmt.Printf("写入结果文件(%s)失败，err: %v\n", reportPath, err)
+			return err
+		}
+		printPayoutSummary(run.Rows)
+		fmt.Printf("每一行的结果已写入 %s，执行 payouts status %s 查看确认状态\n", reportPath, run.ID)
+
+		for _, row := range todo {
+			if row.Status != msgPending {
+				return xerrors.Errorf("line %d: %s", row.Line, row.Error)
+			}
+		}
+		return nil
+	},
+}
+
+var payoutsCmd = &cli.Command{
+	Name:  "payouts",
+	Usage: "查看 send-batch 批次",
+	Subcommands: []*cli.Command{
+		payoutsStatusCmd,
+		payoutsResolveCmd,
+	},
+	Before: func(context *cli.Context) error {
+		return _initDb()
+	},
+}
+
+var payoutsStatusCmd = &cli.Command{
+	Name:      "status",
+	Usage:     "从链上更新并汇总批次的执行状态，不指定批次时列出所有批次",
+	ArgsUsage: "[batch id]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "offline",
+			Usage: "不连接节点，只显示状态文件中记录的状态",
+		},
+		&cli.BoolFlag{
+			Name:  "rows",
+			Usage: "显示每一行的状态",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() == 0 {
+			runs, err := listPayoutRuns()
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			for _, run := range runs {
+				executed := 0
+				for _, row := range run.Rows {
+					if row.Status == msgExecuted {
+						executed++
+					}
+				}
+				fmt.Printf("%s\t%s\t%s\t%d/%d 已执行\n", run.ID, run.CreatedAt.Format("2006-01-02 15:04:05"), run.Input, executed, len(run.Rows))
+			}
+			return nil
+		}
+
+		run, err := loadPayoutRun(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		if !cctx.Bool("offline") {
+			api, closer, err := getFullNodeAPI(cctx)
+			if err != nil {
+				fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+				return err
+			}
+			defer closer()
+
+			from, err := parseAddress(run.From)
+			if err != nil {
+				return err
+			}
+			for _, row := range run.Rows {
+				if row.Status != payoutInvalid {
+					validatePayoutRow(row, from) //nolint:errcheck
+				}
+			}
+			if err := run.refresh(lcli.ReqContext(cctx), api); err != nil {
+				fmt.Printf("更新批次状态失败，err: %v\n", err)
+				return err
+			}
+		}
+
+		fmt.Printf("批次: %s\n文件: %s\n出账账户: %s\n创建时间: %s\n更新时间: %s\n",
+			run.ID, run.Input, run.From, run.CreatedAt.Format("2006-01-02 15:04:05"), run.UpdatedAt.Format("2006-01-02 15:04:05"))
+		printPayoutSummary(run.Rows)
+
+		resume := false
+		for _, row := range run.Rows {
+			if cctx.Bool("rows") || row.Status != msgExecuted {
+				fmt.Printf("%d\t%s\t%s\t%s\t%s\t%s\n", row.Line, row.To, row.Amount, row.Status, row.cidString(), row.Error)
+			}
+			resume = resume || row.needSend()
+		}
+		for _, row := range run.Rows {
+			if row.Status == msgUnknown {
+				fmt.Printf("第 %d 行的nonce已经被使用但找不到对应的消息，请人工确认是否到账后执行 payouts resolve %s %d --sent 或 --resend\n", row.Line, run.ID, row.Line)
+			}
+		}
+		if resume {
+			fmt.Printf("还有未完成的转账，可以执行 send-batch --resume %s 继续\n", run.ID)
+		}
+		return nil
+	},
+}
+
+var payoutsResolveCmd = &cli.Command{
+	Name:      "resolve",
+	Usage:     "人工确认状态为 unknown 的行：--sent 标记为已到账，--resend 在 --resume 时重新发送",
+	ArgsUsage: "<batch id> <line...>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "sent",
+			Usage: "已确认到账，不再发送",
+		},
+		&cli.BoolFlag{
+			Name:  "resend",
+			Usage: "已确认没有到账，继续执行时重新发送",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() < 2 {
+			fmt.Println("必须指定批次和行号")
+			return fmt.Errorf("必须指定批次和行号")
+		}
+		if cctx.Bool("sent") == cctx.Bool("resend") {
+			fmt.Println("必须指定 --sent 或 --resend 其中之一")
+			return fmt.Errorf("必须指定 --sent 或 --resend 其中之一")
+		}
+
+		run, err := loadPayoutRun(cctx.Args().First())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		for _, arg := range cctx.Args().Slice()[1:] {
+			line, err := strconv.Atoi(arg)
+			if err != nil {
+				fmt.Printf("行号 %s 不正确\n", arg)
+				return err
+			}
+			var row *payoutRow
+			for _, r := range run.Rows {
+				if r.Line == line {
+					row = r
+				}
+			}
+			if row == nil {
+				fmt.Printf("批次 %s 没有第 %d 行\n", run.ID, line)
+				return xerrors.Errorf("line %d not found", line)
+			}
+			if row.Status != msgUnknown {
+				fmt.Printf("第 %d 行的状态是 %s，只能确认 unknown 状态的行\n", line, row.Status)
+				return xerrors.Errorf("line %d is %s", line, row.Status)
+			}
+
+			if cctx.Bool("sent") {
+				row.Status = payoutResolved
+				row.Error = "人工确认已到账"
+			} else {
+				row.Error = fmt.Sprintf("人工确认未到账，原消息 %s", row.cidString())
+				row.Status = payoutNew
+				row.Cid = cid.Undef
+				row.Nonce = 0
+			}
+			fmt.Printf("第 %d 行: %s\n", line, row.Error)
+		}
+		return run.save()
+	},
+}
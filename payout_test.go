@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPayoutFile(t *testing.T) {
+	t.Setenv(repoENV, t.TempDir())
+	networkFlag = networkMainnet
+	activeNetwork = ""
+	defer func() {
+		networkFlag = ""
+		activeNetwork = ""
+	}()
+
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		p := filepath.Join(dir, name)
+		assert.Nil(t, os.WriteFile(p, []byte(data), 0644))
+		return p
+	}
+
+	rows, err := readPayoutFile(write("a.csv", "amount,to\n1.5,f01000\n# comment\n0.000000000000000001,f01001\n"), "")
+	assert.Nil(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "f01000", rows[0].To)
+	assert.Equal(t, "1.5", rows[0].Amount)
+	assert.Equal(t, 2, rows[0].Line)
+
+	rows, err = readPayoutFile(write("withdraw.list", "2\tf01000\n\n3\tf01001\n"), "")
+	assert.Nil(t, err)
+	assert.Len(t, rows, 2)
+	assert.Equal(t, "f01001", rows[1].To)
+	assert.Equal(t, "3", rows[1].Amount)
+
+	rows, err = readPayoutFile(write("a.json", `[{"to":"f01000","amount":"1"},{"to":"t01001","amount":"-1"},{"to":"f01002","amount":"x"}]`), "")
+	assert.Nil(t, err)
+	assert.Len(t, rows, 3)
+
+	from, _ := address.NewIDAddress(1002)
+	rows = append(rows, &payoutRow{Line: 4, To: "f01003", Amount: "0"})
+	assert.Equal(t, 3, validatePayoutRows(rows, from))
+	assert.Empty(t, rows[0].Error)
+	assert.Equal(t, abi.NewTokenAmount(1e18), rows[0].amount)
+	assert.Equal(t, payoutInvalid, rows[1].Status)
+
+	_, err = readPayoutFile(write("a.csv", ""), "xml")
+	assert.NotNil(t, err)
+}
+
+func TestPayoutRun(t *testing.T) {
+	t.Setenv(repoENV, t.TempDir())
+
+	from, _ := address.NewIDAddress(1000)
+	c := (&types.Message{To: from, From: from, Value: big.Zero(), GasFeeCap: big.Zero(), GasPremium: big.Zero()}).Cid()
+	rows := []*payoutRow{
+		{Line: 1, To: "f01001", Amount: "1"},
+		{Line: 2, To: "f01002", Amount: "2", Status: msgPending, Nonce: 7, Cid: c},
+		{Line: 3, To: "f01003", Amount: "3", Status: msgDropped, Nonce: 8, Cid: c},
+	}
+	run, err := newPayoutRun("a.csv", from, address.Undef, rows)
+	assert.Nil(t, err)
+
+	loaded, err := loadPayoutRun(run.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "f01000", loaded.From)
+	assert.Len(t, loaded.Rows, 3)
+	assert.Equal(t, cid.Undef, loaded.Rows[0].Cid)
+	assert.Equal(t, c, loaded.Rows[1].Cid)
+	assert.Equal(t, uint64(7), loaded.Rows[1].Nonce)
+
+	assert.True(t, loaded.Rows[0].needSend())
+	assert.False(t, loaded.Rows[1].needSend())
+	assert.True(t, loaded.Rows[2].needSend())
+
+	// 找不到替换消息时不能自动重发
+	assert.False(t, (&payoutRow{Status: msgUnknown}).needSend())
+	assert.False(t, (&payoutRow{Status: payoutResolved}).needSend())
+
+	// 同一秒内创建的批次ID不能重复
+	run2, err := newPayoutRun("a.csv", from, address.Undef, rows)
+	assert.Nil(t, err)
+	assert.NotEqual(t, run.ID, run2.ID)
+
+	runs, err := listPayoutRuns()
+	assert.Nil(t, err)
+	assert.Len(t, runs, 2)
+}
@@ -0,0 +1,800 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	mbuildin "github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/verifreg"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// extendCandidate 待续期的扇区，NewExpiration 已按扇区最大寿命和单次最大续期截断
+type extendCandidate struct {
+	Number        abi.SectorNumber
+	Location      miner.SectorLocation
+	Expiration    abi.ChainEpoch
+	NewExpiration abi.ChainEpoch
+	Claims        map[verifreg.ClaimId]verifreg.Claim
+}
+
+type extendOptions struct {
+	Height     abi.ChainEpoch
+	Tolerance  abi.ChainEpoch
+	DropClaims bool
+	DeclMax    int
+	SectorsMax int
+}
+
+type extendDecl struct {
+	Deadline          uint64
+	Partition         uint64
+	NewExpiration     abi.ChainEpoch
+	Sectors           []uint64            `json:",omitempty"`
+	SectorsWithClaims []miner.SectorClaim `json:",omitempty"`
+}
+
+type extendMessage struct {
+	Extensions []extendDecl
+}
+
+type extendSkip struct {
+	Sector abi.SectorNumber
+	Reason string
+}
+
+// extendPlan 续期计划，可以用 --plan-out 写入文件审核后再用 --plan 发送
+type extendPlan struct {
+	Miner    string
+	Height   abi.ChainEpoch
+	Messages []extendMessage
+	Skipped  []extendSkip `json:",omitempty"`
+}
+
+func (d *extendDecl) count() int {
+	return len(d.Sectors) + len(d.SectorsWithClaims)
+}
+
+func (m *extendMessage) count() (sectors, maintain, drop int) {
+	for _, d := range m.Extensions {
+		sectors += d.count()
+		for _, sc := range d.SectorsWithClaims {
+			maintain += len(sc.MaintainClaims)
+			drop += len(sc.DropClaims)
+		}
+	}
+	return
+}
+
+func (m *extendMessage) params() *miner.ExtendSectorExpiration2Params {
+	p := &miner.ExtendSectorExpiration2Params{}
+	for _, d := range m.Extensions {
+		p.Extensions = append(p.Extensions, miner.ExpirationExtension2{
+			Deadline:          d.Deadline,
+			Partition:         d.Partition,
+			Sectors:           bitfield.NewFromSet(d.Sectors),
+			SectorsWithClaims: d.SectorsWithClaims,
+			NewExpiration:     d.NewExpiration,
+		})
+	}
+	return p
+}
+
+// planSectorClaims 根据扇区上的 Fil+ claim 确定实际的续期高度。
+// claim 的最长期限不够时，允许丢弃的 claim 被丢弃，否则续期高度截断到 claim 的最长期限
+func planSectorClaims(c *extendCandidate, opts extendOptions) (abi.ChainEpoch, *miner.SectorClaim) {
+	if len(c.Claims) == 0 {
+		return c.NewExpiration, nil
+	}
+
+	ids := make([]verifreg.ClaimId, 0, len(c.Claims))
+	for id := range c.Claims {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	// FIP-0045: claim 的最短期限已过且扇区在最后 30 天内才可以丢弃
+	canDrop := func(cl verifreg.Claim) bool {
+		return opts.DropClaims &&
+			opts.Height > cl.TermStart+cl.TermMin &&
+			opts.Height > c.Expiration-mbuildin.EndOfLifeClaimDropPeriod
+	}
+
+	newExp := c.NewExpiration
+	for _, id := range ids {
+		cl := c.Claims[id]
+		if end := cl.TermStart + cl.TermMax; end < newExp && !canDrop(cl) {
+			newExp = end
+		}
+	}
+
+	sc := &miner.SectorClaim{SectorNumber: c.Number}
+	for _, id := range ids {
+		cl := c.Claims[id]
+		if cl.TermStart+cl.TermMax >= newExp {
+			sc.MaintainClaims = append(sc.MaintainClaims, id)
+		} else {
+			sc.DropClaims = append(sc.DropClaims, id)
+		}
+	}
+	return newExp, sc
+}
+
+// buildExtendPlan 按 deadline/partition 和续期高度分组，相差不超过 tolerance 的续期高度合并为较早的一个，
+// 再按每条消息的声明数和扇区数上限拆分
+func buildExtendPlan(cands []extendCandidate, opts extendOptions) ([]extendMessage, []extendSkip) {
+	type item struct {
+		number abi.SectorNumber
+		exp    abi.ChainEpoch
+		newExp abi.ChainEpoch
+		claim  *miner.SectorClaim
+	}
+
+	var skipped []extendSkip
+	byLoc := map[miner.SectorLocation][]item{}
+	for i := range cands {
+		c := &cands[i]
+		newExp, sc := planSectorClaims(c, opts)
+		if newExp <= c.Expiration+opts.Tolerance {
+			reason := "已接近最大寿命"
+			if newExp < c.NewExpiration {
+				reason = "Fil+ claim 期限不足"
+			}
+			skipped = append(skipped, extendSkip{Sector: c.Number, Reason: reason})
+			continue
+		}
+		byLoc[c.Location] = append(byLoc[c.Location], item{number: c.Number, exp: c.Expiration, newExp: newExp, claim: sc})
+	}
+
+	locs := make([]miner.SectorLocation, 0, len(byLoc))
+	for l := range byLoc {
+		locs = append(locs, l)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].Deadline != locs[j].Deadline {
+			return locs[i].Deadline < locs[j].Deadline
+		}
+		return locs[i].Partition < locs[j].Partition
+	})
+
+	var decls []extendDecl
+	for _, l := range locs {
+		items := byLoc[l]
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].newExp != items[j].newExp {
+				return items[i].newExp < items[j].newExp
+			}
+			return items[i].number < items[j].number
+		})
+
+		var cur *extendDecl
+		for _, it := range items {
+			if cur == nil || it.newExp-cur.NewExpiration > opts.Tolerance || cur.NewExpiration <= it.exp {
+				decls = append(decls, extendDecl{Deadline: l.Deadline, Partition: l.Partition, NewExpiration: it.newExp})
+				cur = &decls[len(decls)-1]
+			}
+			if it.claim != nil {
+				cur.SectorsWithClaims = append(cur.SectorsWithClaims, *it.claim)
+			} else {
+				cur.Sectors = append(cur.Sectors, uint64(it.number))
+			}
+		}
+	}
+
+	var msgs []extendMessage
+	cur := extendMessage{}
+	count := 0
+	flush := func() {
+		if len(cur.Extensions) > 0 {
+			msgs = append(msgs, cur)
+		}
+		cur = extendMessage{}
+		count = 0
+	}
+	for _, d := range decls {
+		for d.count() > 0 {
+			if count == opts.SectorsMax || len(cur.Extensions) == opts.DeclMax {
+				flush()
+			}
+
+			part := extendDecl{Deadline: d.Deadline, Partition: d.Partition, NewExpiration: d.NewExpiration}
+			room := opts.SectorsMax - count
+			n := len(d.Sectors)
+			if n > room {
+				n = room
+			}
+			part.Sectors, d.Sectors = d.Sectors[:n], d.Sectors[n:]
+			room -= n
+			n = len(d.SectorsWithClaims)
+			if n > room {
+				n = room
+			}
+			part.SectorsWithClaims, d.SectorsWithClaims = d.SectorsWithClaims[:n], d.SectorsWithClaims[n:]
+
+			cur.Extensions = append(cur.Extensions, part)
+			count += part.count()
+		}
+	}
+	flush()
+	return msgs, skipped
+}
+
+// extendState 续期用到的链上状态：有效扇区、扇区所在分区和 Fil+ claim
+type extendState struct {
+	active    []*miner.SectorOnChainInfo
+	infos     map[abi.SectorNumber]*miner.SectorOnChainInfo
+	locations map[abi.SectorNumber]miner.SectorLocation
+	claims    map[verifreg.ClaimId]verifreg.Claim
+	claimIds  map[abi.SectorNumber][]verifreg.ClaimId
+}
+
+func loadExtendState(ctx context.Context, api v0api.FullNode, maddr address.Address) (*extendState, error) {
+	active, err := api.StateMinerActiveSectors(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("getting miner sector infos: %w", err)
+	}
+	st := &extendState{
+		active:    active,
+		infos:     make(map[abi.SectorNumber]*miner.SectorOnChainInfo, len(active)),
+		locations: map[abi.SectorNumber]miner.SectorLocation{},
+	}
+	for _, si := range active {
+		st.infos[si.SectorNumber] = si
+	}
+
+	mact, err := api.StateGetActor(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return nil, err
+	}
+	tbs := blockstore.NewTieredBstore(blockstore.NewAPIBlockstore(api), blockstore.NewMemory())
+	store := adt.WrapStore(ctx, cbor.NewCborStore(tbs))
+	mas, err := miner.Load(store, mact)
+	if err != nil {
+		return nil, err
+	}
+	if err := mas.ForEachDeadline(func(dlIdx uint64, dl miner.Deadline) error {
+		return dl.ForEachPartition(func(partIdx uint64, part miner.Partition) error {
+			as, err := part.ActiveSectors()
+			if err != nil {
+				return err
+			}
+			return as.ForEach(func(i uint64) error {
+				st.locations[abi.SectorNumber(i)] = miner.SectorLocation{Deadline: dlIdx, Partition: partIdx}
+				return nil
+			})
+		})
+	}); err != nil {
+		return nil, xerrors.Errorf("loading sector locations: %w", err)
+	}
+
+	vact, err := api.StateGetActor(ctx, mbuildin.VerifiedRegistryActorAddr, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to lookup verifreg actor: %w", err)
+	}
+	vst, err := verifreg.Load(store, vact)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to load verifreg state: %w", err)
+	}
+	st.claims, err = vst.GetClaims(maddr)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to lookup claims for miner: %w", err)
+	}
+	st.claimIds, err = vst.GetClaimIdsBySector(maddr)
+	if err != nil {
+		return nil, xerrors.Errorf("failed to lookup claim IDs by sector: %w", err)
+	}
+	return st, nil
+}
+
+// loadExtendCandidates 读取扇区信息、所在分区和 Fil+ claim，生成续期候选
+func loadExtendCandidates(ctx context.Context, cctx *cli.Context, api v0api.FullNode, maddr address.Address, height abi.ChainEpoch) ([]extendCandidate, []extendSkip, error) {
+	nv, err := api.StateNetworkVersion(ctx, types.EmptyTSK)
+	if err != nil {
+		return nil, nil, err
+	}
+	maxExtension, err := policy.GetMaxSectorExpirationExtension(nv)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("failed to get max extension: %w", err)
+	}
+
+	st, err := loadExtendState(ctx, api, maddr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 选择扇区：命令行参数、文件或者按到期时间的规则
+	var numbers []abi.SectorNumber
+	var skipped []extendSkip
+	for _, s := range cctx.Args().Slice() {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("扇区编号 %s 不正确: %w", s, err)
+		}
+		numbers = append(numbers, abi.SectorNumber(n))
+	}
+	if path := cctx.String("from-file"); path != "" {
+		fromFile, err := readSectorFile(path)
+		if err != nil {
+			return nil, nil, xerrors.Errorf("读取扇区文件失败: %w", err)
+		}
+		for _, n := range fromFile {
+			numbers = append(numbers, abi.SectorNumber(n))
+		}
+	}
+	if len(numbers) == 0 {
+		if !cctx.IsSet("expiring-within") && !cctx.Bool("v1-sectors") {
+			return nil, nil, xerrors.Errorf("必须指定扇区、--from-file、--expiring-within 或 --v1-sectors")
+		}
+		for _, si := range st.active {
+			if cctx.Bool("v1-sectors") && !isV1Proof(si.SealProof) {
+				continue
+			}
+			if si.Expiration < height+abi.ChainEpoch(cctx.Int64("expiration-ignore")) {
+				continue
+			}
+			if cctx.IsSet("expiring-within") && si.Expiration > height+abi.ChainEpoch(cctx.Int64("expiring-within"))*mbuildin.EpochsInDay {
+				continue
+			}
+			if cctx.IsSet("expiration-cutoff") && si.Expiration > height+abi.ChainEpoch(cctx.Int64("expiration-cutoff")) {
+				continue
+			}
+			numbers = append(numbers, si.SectorNumber)
+		}
+	}
+
+	seen := map[abi.SectorNumber]bool{}
+	var cands []extendCandidate
+	for _, n := range numbers {
+		if seen[n] {
+			continue
+		}
+		seen[n] = true
+
+		si, ok := st.infos[n]
+		if !ok {
+			skipped = append(skipped, extendSkip{Sector: n, Reason: "不是有效扇区"})
+			continue
+		}
+		loc, ok := st.locations[n]
+		if !ok {
+			return nil, nil, xerrors.Errorf("sector %d not found in any partition", n)
+		}
+
+		// 扇区最大寿命和单次最大续期
+		maxExp := si.Activation + policy.GetSectorMaxLifetime(si.SealProof, nv)
+		if limit := height + maxExtension; limit < maxExp {
+			maxExp = limit
+		}
+		newExp := maxExp
+		if cctx.IsSet("new-expiration") {
+			newExp = abi.ChainEpoch(cctx.Int64("new-expiration"))
+		} else if cctx.IsSet("extension") {
+			newExp = si.Expiration + abi.ChainEpoch(cctx.Int64("extension"))
+		}
+		if newExp > maxExp {
+			newExp = maxExp
+		}
+
+		c := extendCandidate{Number: n, Location: loc, Expiration: si.Expiration, NewExpiration: newExp}
+		for _, id := range st.claimIds[n] {
+			cl, ok := st.claims[id]
+			if !ok {
+				return nil, nil, xerrors.Errorf("failed to find claim %d for sector %d", id, n)
+			}
+			if c.Claims == nil {
+				c.Claims = map[verifreg.ClaimId]verifreg.Claim{}
+			}
+			c.Claims[id] = cl
+		}
+		cands = append(cands, c)
+	}
+	return cands, skipped, nil
+}
+
+func readExtendPlan(path string) (*extendPlan, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan extendPlan
+	if err := json.Unmarshal(b, &plan); err != nil {
+		return nil, xerrors.Errorf("解析续期计划失败: %w", err)
+	}
+	return &plan, nil
+}
+
+func writeExtendPlan(path string, plan *extendPlan) error {
+	b, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// check 按当前链上状态校验续期计划：扇区仍然有效、所在分区不变、续期高度晚于当前到期高度，
+// 带 claim 的扇区 claim 没有变化且保留的 claim 期限足够
+func (plan *extendPlan) check(st *extendState) error {
+	for i, m := range plan.Messages {
+		for _, d := range m.Extensions {
+			loc := miner.SectorLocation{Deadline: d.Deadline, Partition: d.Partition}
+			sector := func(n abi.SectorNumber) error {
+				si, ok := st.infos[n]
+				if !ok {
+					return xerrors.Errorf("第 %d 条消息: 扇区 %d 已经不是有效扇区", i+1, n)
+				}
+				if st.locations[n] != loc {
+					return xerrors.Errorf("第 %d 条消息: 扇区 %d 所在分区已经变化", i+1, n)
+				}
+				if d.NewExpiration <= si.Expiration {
+					return xerrors.Errorf("第 %d 条消息: 扇区 %d 当前到期高度 %d 不早于续期高度 %d", i+1, n, si.Expiration, d.NewExpiration)
+				}
+				return nil
+			}
+
+			for _, n := range d.Sectors {
+				if err := sector(abi.SectorNumber(n)); err != nil {
+					return err
+				}
+				if len(st.claimIds[abi.SectorNumber(n)]) > 0 {
+					return xerrors.Errorf("第 %d 条消息: 扇区 %d 现在有 Fil+ claim", i+1, n)
+				}
+			}
+			for _, sc := range d.SectorsWithClaims {
+				if err := sector(sc.SectorNumber); err != nil {
+					return err
+				}
+				planned := map[verifreg.ClaimId]bool{}
+				for _, id := range sc.MaintainClaims {
+					planned[id] = true
+					if cl, ok := st.claims[id]; ok && cl.TermStart+cl.TermMax < d.NewExpiration {
+						return xerrors.Errorf("第 %d 条消息: 扇区 %d 的 claim %d 期限不足", i+1, sc.SectorNumber, id)
+					}
+				}
+				for _, id := range sc.DropClaims {
+					planned[id] = true
+				}
+				ids := st.claimIds[sc.SectorNumber]
+				if len(ids) != len(planned) {
+					return xerrors.Errorf("第 %d 条消息: 扇区 %d 的 Fil+ claim 已经变化", i+1, sc.SectorNumber)
+				}
+				for _, id := range ids {
+					if !planned[id] {
+						return xerrors.Errorf("第 %d 条消息: 扇区 %d 的 Fil+ claim 已经变化", i+1, sc.SectorNumber)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (plan *extendPlan) print(height abi.ChainEpoch) error {
+	tw := tablewriter.New(
+		tablewriter.Col("Message"),
+		tablewriter.Col("Declarations"),
+		tablewriter.Col("Sectors"),
+		tablewriter.Col("MaintainClaims"),
+		tablewriter.Col("DropClaims"),
+		tablewriter.Col("NewExpiration"))
+	total := 0
+	for i := range plan.Messages {
+		m := &plan.Messages[i]
+		sectors, maintain, drop := m.count()
+		total += sectors
+
+		minExp, maxExp := m.Extensions[0].NewExpiration, m.Extensions[0].NewExpiration
+		for _, d := range m.Extensions {
+			if d.NewExpiration < minExp {
+				minExp = d.NewExpiration
+			}
+			if d.NewExpiration > maxExp {
+				maxExp = d.NewExpiration
+			}
+		}
+		exp := EpochTimeHuman(height, minExp)
+		if maxExp != minExp {
+			exp += " ~ " + EpochTimeHuman(height, maxExp)
+		}
+
+		tw.Write(map[string]interface{}{
+			"Message":        i + 1,
+			"Declarations":   len(m.Extensions),
+			"Sectors":        sectors,
+			"MaintainClaims": maintain,
+			"DropClaims":     drop,
+			"NewExpiration":  exp,
+		})
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	reasons := map[string]int{}
+	for _, s := range plan.Skipped {
+		reasons[s.Reason]++
+	}
+	fmt.Printf("\n矿工 %s 续期 %d 个扇区，共 %d 条消息\n", plan.Miner, total, len(plan.Messages))
+	for r, n := range reasons {
+		fmt.Printf("跳过 %d 个扇区: %s\n", n, r)
+	}
+	return nil
+}
+
+var sectorsExtendCmd = &cli.Command{
+	Name:      "extend",
+	Usage:     "Extend miner`s sector expiration",
+	ArgsUsage: "<sectorNumbers...>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "miner",
+			Usage:    "miner id .eg(f02420)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "from-file",
+			Usage: "从文件读取扇区编号，每行第一列为扇区编号，可以使用 sectors expired 导出的文件",
+		},
+		&cli.Int64Flag{
+			Name:  "expiring-within",
+			Usage: "选择在指定天数内到期的全部扇区",
+		},
+		&cli.BoolFlag{
+			Name:  "v1-sectors",
+			Usage: "选择全部 v1 扇区",
+		},
+		&cli.Int64Flag{
+			Name:  "expiration-ignore",
+			Value: 120,
+			Usage: "按规则选择扇区时，跳过在 <ignore> 个高度内到期的扇区",
+		},
+		&cli.Int64Flag{
+			Name:  "expiration-cutoff",
+			Usage: "按规则选择扇区时，跳过在 <cutoff> 个高度之后到期的扇区",
+		},
+		&cli.Int64Flag{
+			Name:  "new-expiration",
+			Usage: "续期到的高度，默认续期到扇区允许的最大寿命",
+		},
+		&cli.Int64Flag{
+			Name:  "extension",
+			Usage: "在当前到期高度上延长的高度数",
+		},
+		&cli.Int64Flag{
+			Name:  "tolerance",
+			Value: 20160,
+			Usage: "延长不足 <tolerance> 个高度的扇区不续期，续期高度相差不超过 <tolerance> 的扇区合并为一个声明",
+		},
+		&cli.BoolFlag{
+			Name:  "drop-claims",
+			Usage: "允许丢弃期限不足的 Fil+ claim（需满足 FIP-0045 的条件），否则续期高度截断到 claim 的最长期限",
+		},
+		&cli.IntFlag{
+			Name:  "max-sectors",
+			Usage: "每条消息包含的最大扇区数",
+		},
+		&cli.StringFlag{
+			Name:  "plan-out",
+			Usage: "把续期计划写入文件，审核后可以用 --plan 发送",
+		},
+		&cli.StringFlag{
+			Name:  "plan",
+			Usage: "按 --plan-out 生成的续期计划发送",
+		},
+		&cli.Int64Flag{
+			Name:  "plan-max-age",
+			Value: 2880,
+			Usage: "拒绝发送生成时间超过 <age> 个高度的续期计划",
+		},
+		waitFlag,
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		api, nCloser, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		defer nCloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.String("miner"))
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.String("miner"), err)
+			return err
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		var plan *extendPlan
+		if path := cctx.String("plan"); path != "" {
+			plan, err = readExtendPlan(path)
+			if err != nil {
+				fmt.Println("读取续期计划失败,", err)
+				return err
+			}
+			if plan.Miner != maddr.String() {
+				fmt.Printf("续期计划是矿工 %s 的，不是 %s\n", plan.Miner, maddr)
+				return xerrors.Errorf("plan miner mismatch")
+			}
+			if age := head.Height() - plan.Height; age > abi.ChainEpoch(cctx.Int64("plan-max-age")) {
+				fmt.Printf("续期计划是在高度 %d 生成的，已经过去 %d 个高度，请重新生成\n", plan.Height, age)
+				return xerrors.Errorf("plan is too old")
+			}
+			st, err := loadExtendState(ctx, api, maddr)
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			if err := plan.check(st); err != nil {
+				fmt.Println("续期计划与当前链上状态不一致，请重新生成,", err)
+				return err
+			}
+		} else {
+			nv, err := api.StateNetworkVersion(ctx, types.EmptyTSK)
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			declMax, err := policy.GetDeclarationsMax(nv)
+			if err != nil {
+				return xerrors.Errorf("failed to get declarations max: %w", err)
+			}
+			sectorsMax, err := policy.GetAddressedSectorsMax(nv)
+			if err != nil {
+				return xerrors.Errorf("failed to get addressed sectors max: %w", err)
+			}
+			if cctx.IsSet("max-sectors") {
+				n := cctx.Int("max-sectors")
+				if n <= 0 {
+					fmt.Println("--max-sectors 必须大于 0")
+					return xerrors.Errorf("max-sectors must be positive")
+				}
+				if n > sectorsMax {
+					fmt.Printf("--max-sectors 不能超过 %d\n", sectorsMax)
+					return xerrors.Errorf("the specified max-sectors exceeds the maximum limit")
+				}
+				sectorsMax = n
+			}
+
+			cands, skipped, err := loadExtendCandidates(ctx, cctx, api, maddr, head.Height())
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			msgs, skipped2 := buildExtendPlan(cands, extendOptions{
+				Height:     head.Height(),
+				Tolerance:  abi.ChainEpoch(cctx.Int64("tolerance")),
+				DropClaims: cctx.Bool("drop-claims"),
+				DeclMax:    declMax,
+				SectorsMax: sectorsMax,
+			})
+			plan = &extendPlan{
+				Miner:    maddr.String(),
+				Height:   head.Height(),
+				Messages: msgs,
+				Skipped:  append(skipped, skipped2...),
+			}
+		}
+
+		if err := plan.print(head.Height()); err != nil {
+			return err
+		}
+		if path := cctx.String("plan-out"); path != "" {
+			if err := writeExtendPlan(path, plan); err != nil {
+				fmt.Println("写入续期计划失败,", err)
+				return err
+			}
+			fmt.Printf("续期计划已写入 %s，审核后使用 --plan %s 发送\n", path, path)
+			return nil
+		}
+
+		if len(plan.Messages) == 0 {
+			fmt.Println("nothing to extend")
+			return nil
+		}
+
+		sender, err := minerControlSender(ctx, api, maddr, "")
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		fp, err := getFeeParams(cctx)
+		if err != nil {
+			return err
+		}
+
+		if !dryRun {
+			fmt.Printf("将由 %s%s 向矿工 %s 发送 %d 条 ExtendSectorExpiration2 消息\n", sender, addressLabel(sender), maddr, len(plan.Messages))
+			if err := confirmSend(cctx); err != nil {
+				if err == errCanceled {
+					return nil
+				}
+				return err
+			}
+		}
+
+		for i := range plan.Messages {
+			sp, aerr := actors.SerializeParams(plan.Messages[i].params())
+			if aerr != nil {
+				return xerrors.Errorf("serializing params: %w", aerr)
+			}
+
+			msg := &types.Message{
+				From:   sender,
+				To:     maddr,
+				Method: mbuildin.MethodsMiner.ExtendSectorExpiration2,
+
+				Value:  big.Zero(),
+				Params: sp,
+			}
+			fp.apply(msg)
+
+			if dryRun {
+				emsg, err := estimateMessageGas(ctx, cctx, api, msg)
+				if err != nil {
+					fmt.Printf("评估消息的gas费用失败， err:%v\n", err)
+					emsg = msg
+				} else {
+					printFee(emsg)
+				}
+				if err := simulateMessage(ctx, api, emsg); err != nil {
+					return err
+				}
+				continue
+			}
+
+			c, err := pushMessage(ctx, cctx, api, msg)
+			if err != nil {
+				fmt.Printf("第 %d 条消息发送失败，err:%v\n", i+1, err)
+				return err
+			}
+			fmt.Printf("第 %d 条消息: %s\n", i+1, c)
+
+			if cctx.Bool(waitFlag.Name) {
+				if _, err := waitMessage(ctx, cctx, api, c); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	},
+}
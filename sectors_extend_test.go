@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/verifreg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanSectorClaims(t *testing.T) {
+	c := &extendCandidate{
+		Number:        1,
+		Expiration:    1500,
+		NewExpiration: 5000,
+		Claims: map[verifreg.ClaimId]verifreg.Claim{
+			7: {TermStart: 0, TermMin: 100, TermMax: 2005},
+			8: {TermStart: 0, TermMin: 100, TermMax: 9000},
+		},
+	}
+
+	// 不允许丢弃时截断到 claim 的最长期限
+	newExp, sc := planSectorClaims(c, extendOptions{Height: 1000})
+	assert.Equal(t, abi.ChainEpoch(2005), newExp)
+	assert.Equal(t, []verifreg.ClaimId{7, 8}, sc.MaintainClaims)
+	assert.Empty(t, sc.DropClaims)
+
+	newExp, sc = planSectorClaims(c, extendOptions{Height: 1000, DropClaims: true})
+	assert.Equal(t, abi.ChainEpoch(5000), newExp)
+	assert.Equal(t, []verifreg.ClaimId{8}, sc.MaintainClaims)
+	assert.Equal(t, []verifreg.ClaimId{7}, sc.DropClaims)
+
+	// claim 的最短期限未过，不能丢弃
+	newExp, _ = planSectorClaims(c, extendOptions{Height: 50, DropClaims: true})
+	assert.Equal(t, abi.ChainEpoch(2005), newExp)
+}
+
+func TestBuildExtendPlan(t *testing.T) {
+	l0 := miner.SectorLocation{Deadline: 0, Partition: 0}
+	l1 := miner.SectorLocation{Deadline: 1, Partition: 0}
+	cands := []extendCandidate{
+		{Number: 3, Location: l0, Expiration: 2000, NewExpiration: 6000},
+		{Number: 2, Location: l0, Expiration: 2000, NewExpiration: 5005},
+		{Number: 1, Location: l0, Expiration: 2000, NewExpiration: 5000},
+		{Number: 4, Location: l1, Expiration: 2000, NewExpiration: 5000, Claims: map[verifreg.ClaimId]verifreg.Claim{
+			1: {TermStart: 0, TermMin: 100, TermMax: 4000},
+		}},
+		{Number: 5, Location: l1, Expiration: 4995, NewExpiration: 5000},
+		{Number: 6, Location: l1, Expiration: 2000, NewExpiration: 5000, Claims: map[verifreg.ClaimId]verifreg.Claim{
+			2: {TermStart: 0, TermMin: 100, TermMax: 2005},
+		}},
+	}
+
+	msgs, skipped := buildExtendPlan(cands, extendOptions{Height: 1000, Tolerance: 10, DeclMax: 2, SectorsMax: 3})
+	assert.Equal(t, []extendSkip{
+		{Sector: 5, Reason: "已接近最大寿命"},
+		{Sector: 6, Reason: "Fil+ claim 期限不足"},
+	}, skipped)
+
+	assert.Len(t, msgs, 2)
+	assert.Len(t, msgs[0].Extensions, 2)
+	assert.Equal(t, abi.ChainEpoch(5000), msgs[0].Extensions[0].NewExpiration)
+	assert.Equal(t, []uint64{1, 2}, msgs[0].Extensions[0].Sectors)
+	assert.Equal(t, []uint64{3}, msgs[0].Extensions[1].Sectors)
+
+	assert.Equal(t, uint64(1), msgs[1].Extensions[0].Deadline)
+	assert.Equal(t, abi.ChainEpoch(4000), msgs[1].Extensions[0].NewExpiration)
+	assert.Empty(t, msgs[1].Extensions[0].Sectors)
+	assert.Equal(t, abi.SectorNumber(4), msgs[1].Extensions[0].SectorsWithClaims[0].SectorNumber)
+
+	// 单个分区超过扇区上限时拆分
+	msgs, _ = buildExtendPlan(cands[:3], extendOptions{Height: 1000, Tolerance: 1000, DeclMax: 10, SectorsMax: 2})
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, []uint64{1, 2}, msgs[0].Extensions[0].Sectors)
+	assert.Equal(t, []uint64{3}, msgs[1].Extensions[0].Sectors)
+}
+
+func TestExtendPlanCheck(t *testing.T) {
+	l0 := miner.SectorLocation{Deadline: 0, Partition: 0}
+	st := &extendState{
+		infos: map[abi.SectorNumber]*miner.SectorOnChainInfo{
+			1: {SectorNumber: 1, Expiration: 2000},
+			2: {SectorNumber: 2, Expiration: 2000},
+		},
+		locations: map[abi.SectorNumber]miner.SectorLocation{1: l0, 2: l0},
+		claims: map[verifreg.ClaimId]verifreg.Claim{
+			7: {TermStart: 0, TermMin: 100, TermMax: 9000},
+		},
+		claimIds: map[abi.SectorNumber][]verifreg.ClaimId{2: {7}},
+	}
+	plan := &extendPlan{Messages: []extendMessage{{Extensions: []extendDecl{{
+		NewExpiration:     5000,
+		Sectors:           []uint64{1},
+		SectorsWithClaims: []miner.SectorClaim{{SectorNumber: 2, MaintainClaims: []verifreg.ClaimId{7}}},
+	}}}}}
+	assert.NoError(t, plan.check(st))
+
+	// 扇区换了分区
+	st.locations[1] = miner.SectorLocation{Deadline: 1, Partition: 0}
+	assert.Error(t, plan.check(st))
+	st.locations[1] = l0
+
+	// 扇区已经续期
+	st.infos[1].Expiration = 5000
+	assert.Error(t, plan.check(st))
+	st.infos[1].Expiration = 2000
+
+	// claim 有变化
+	st.claims[8] = verifreg.Claim{TermMax: 9000}
+	st.claimIds[2] = []verifreg.ClaimId{7, 8}
+	assert.Error(t, plan.check(st))
+}
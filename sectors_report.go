@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+const (
+	reportGroupDay   = "day"
+	reportGroupWeek  = "week"
+	reportGroupMonth = "month"
+)
+
+// sectorReportRow 单个扇区的到期信息
+type sectorReportRow struct {
+	Sector        abi.SectorNumber
+	Expiration    abi.ChainEpoch
+	Time          string
+	Bucket        string
+	Proof         string
+	Verified      bool
+	RawPower      abi.StoragePower
+	QAPower       abi.StoragePower
+	InitialPledge types.FIL
+}
+
+// sectorReportBucket 一个时间段内到期的扇区汇总
+type sectorReportBucket struct {
+	Bucket          string
+	Sectors         int
+	VerifiedSectors int
+	RawPower        abi.StoragePower
+	QAPower         abi.StoragePower
+	InitialPledge   types.FIL
+}
+
+type sectorReport struct {
+	Miner   string
+	Height  abi.ChainEpoch
+	Group   string
+	Buckets []*sectorReportBucket
+	Total   *sectorReportBucket
+	Sectors []sectorReportRow `json:",omitempty"`
+}
+
+// reportBucket 返回时间所在的分组：day 为日期，week 为所在周的周一，month 为年月
+func reportBucket(t time.Time, group string) (string, error) {
+	switch group {
+	case reportGroupDay:
+		return t.Format("2006-01-02"), nil
+	case reportGroupWeek:
+		return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7)).Format("2006-01-02"), nil
+	case reportGroupMonth:
+		return t.Format("2006-01"), nil
+	}
+	return "", xerrors.Errorf("--group 的值 %s 不正确，可选: day, week, month", group)
+}
+
+// isV1Proof v1 扇区最多只能续期到 540 天
+func isV1Proof(proof abi.RegisteredSealProof) bool {
+	return proof < abi.RegisteredSealProof_StackedDrg2KiBV1_1
+}
+
+func newSectorReportRow(si *miner.SectorOnChainInfo, expireAt time.Time, group string) (sectorReportRow, error) {
+	size, err := si.SealProof.SectorSize()
+	if err != nil {
+		return sectorReportRow{}, xerrors.Errorf("sector %d: %w", si.SectorNumber, err)
+	}
+	bucket, err := reportBucket(expireAt, group)
+	if err != nil {
+		return sectorReportRow{}, err
+	}
+
+	proof := "v1_1"
+	if isV1Proof(si.SealProof) {
+		proof = "v1"
+	}
+	return sectorReportRow{
+		Sector:        si.SectorNumber,
+		Expiration:    si.Expiration,
+		Time:          expireAt.Format("2006-01-02 15:04"),
+		Bucket:        bucket,
+		Proof:         proof,
+		Verified:      !si.VerifiedDealWeight.IsZero(),
+		RawPower:      abi.NewStoragePower(int64(size)),
+		QAPower:       builtin.QAPowerForWeight(size, si.Expiration-si.PowerBaseEpoch, si.DealWeight, si.VerifiedDealWeight),
+		InitialPledge: types.FIL(si.InitialPledge),
+	}, nil
+}
+
+// buildSectorBuckets 按到期时间顺序汇总每个分组的扇区数、算力和初始质押
+func buildSectorBuckets(rows []sectorReportRow) ([]*sectorReportBucket, *sectorReportBucket) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Expiration != rows[j].Expiration {
+			return rows[i].Expiration < rows[j].Expiration
+		}
+		return rows[i].Sector < rows[j].Sector
+	})
+
+	newBucket := func(name string) *sectorReportBucket {
+		return &sectorReportBucket{
+			Bucket:        name,
+			RawPower:      big.Zero(),
+			QAPower:       big.Zero(),
+			InitialPledge: types.FIL(big.Zero()),
+		}
+	}
+	add := func(b *sectorReportBucket, r sectorReportRow) {
+		b.Sectors++
+		if r.Verified {
+			b.VerifiedSectors++
+		}
+		b.RawPower = big.Add(b.RawPower, r.RawPower)
+		b.QAPower = big.Add(b.QAPower, r.QAPower)
+		b.InitialPledge = types.FIL(big.Add(big.Int(b.InitialPledge), big.Int(r.InitialPledge)))
+	}
+
+	var buckets []*sectorReportBucket
+	total := newBucket("total")
+	for _, r := range rows {
+		if len(buckets) == 0 || buckets[len(buckets)-1].Bucket != r.Bucket {
+			buckets = append(buckets, newBucket(r.Bucket))
+		}
+		add(buckets[len(buckets)-1], r)
+		add(total, r)
+	}
+	return buckets, total
+}
+
+func (r *sectorReport) writeCSV(w io.Writer, sectors bool) error {
+	cw := csv.NewWriter(w)
+	if sectors {
+		_ = cw.Write([]string{"sector", "expiration", "time", "bucket", "proof", "verified", "raw_power", "qa_power", "initial_pledge"})
+		for _, s := range r.Sectors {
+			_ = cw.Write([]string{
+				strconv.FormatUint(uint64(s.Sector), 10),
+				strconv.FormatInt(int64(s.Expiration), 10),
+				s.Time,
+				s.Bucket,
+				s.Proof,
+				strconv.FormatBool(s.Verified),
+				s.RawPower.String(),
+				s.QAPower.String(),
+				s.InitialPledge.Unitless(),
+			})
+		}
+	} else {
+		_ = cw.Write([]string{"bucket", "sectors", "verified_sectors", "raw_power", "qa_power", "initial_pledge"})
+		for _, b := range append(r.Buckets, r.Total) {
+			_ = cw.Write([]string{
+				b.Bucket,
+				strconv.Itoa(b.Sectors),
+				strconv.Itoa(b.VerifiedSectors),
+				b.RawPower.String(),
+				b.QAPower.String(),
+				b.InitialPledge.Unitless(),
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (r *sectorReport) writeTable(w io.Writer, sectors bool) error {
+	if sectors {
+		tw := tablewriter.New(
+			tablewriter.Col("Sector"),
+			tablewriter.Col("Expiration"),
+			tablewriter.Col("Time"),
+			tablewriter.Col("Proof"),
+			tablewriter.Col("Verified"),
+			tablewriter.Col("QAPower"),
+			tablewriter.Col("InitialPledge"))
+		for _, s := range r.Sectors {
+			tw.Write(map[string]interface{}{
+				"Sector":        s.Sector,
+				"Expiration":    s.Expiration,
+				"Time":          s.Time,
+				"Proof":         s.Proof,
+				"Verified":      s.Verified,
+				"QAPower":       types.SizeStr(s.QAPower),
+				"InitialPledge": s.InitialPledge,
+			})
+		}
+		if err := tw.Flush(w); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+
+	tw := tablewriter.New(
+		tablewriter.Col("Bucket"),
+		tablewriter.Col("Sectors"),
+		tablewriter.Col("Verified"),
+		tablewriter.Col("RawPower"),
+		tablewriter.Col("QAPower"),
+		tablewriter.Col("InitialPledge"))
+	for _, b := range append(r.Buckets, r.Total) {
+		tw.Write(map[string]interface{}{
+			"Bucket":        b.Bucket,
+			"Sectors":       b.Sectors,
+			"Verified":      b.VerifiedSectors,
+			"RawPower":      types.SizeStr(b.RawPower),
+			"QAPower":       types.SizeStr(b.QAPower),
+			"InitialPledge": b.InitialPledge,
+		})
+	}
+	return tw.Flush(w)
+}
+
+// minerLiveSectors 读取矿工全部存活扇区，包括错误和未证明的扇区
+func minerLiveSectors(ctx context.Context, api v0api.FullNode, maddr address.Address, ts *types.TipSet) ([]*miner.SectorOnChainInfo, error) {
+	mact, err := api.StateGetActor(ctx, maddr, ts.Key())
+	if err != nil {
+		return nil, err
+	}
+	tbs := blockstore.NewTieredBstore(blockstore.NewAPIBlockstore(api), blockstore.NewMemory())
+	mas, err := miner.Load(adt.WrapStore(ctx, cbor.NewCborStore(tbs)), mact)
+	if err != nil {
+		return nil, err
+	}
+
+	var live []bitfield.BitField
+	if err := mas.ForEachDeadline(func(_ uint64, dl miner.Deadline) error {
+		return dl.ForEachPartition(func(_ uint64, part miner.Partition) error {
+			ls, err := part.LiveSectors()
+			if err != nil {
+				return err
+			}
+			live = append(live, ls)
+			return nil
+		})
+	}); err != nil {
+		return nil, xerrors.Errorf("loading live sectors: %w", err)
+	}
+	all, err := bitfield.MultiMerge(live...)
+	if err != nil {
+		return nil, err
+	}
+	return api.StateMinerSectors(ctx, maddr, &all, ts.Key())
+}
+
+var sectorsReportCmd = &cli.Command{
+	Name:      "report",
+	Usage:     "按天、周或月汇总扇区到期，统计到期的原值算力、有效算力和释放的初始质押",
+	ArgsUsage: "<minerId>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "group",
+			Usage: "分组方式: day, week, month",
+			Value: reportGroupMonth,
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "输出格式: table, csv, json",
+			Value: "table",
+		},
+		&cli.BoolFlag{
+			Name:  "sectors",
+			Usage: "同时输出每个扇区的明细，csv 格式时只输出扇区明细",
+		},
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "输出到文件，默认输出到终端",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			fmt.Println("必须指定矿工号")
+			return fmt.Errorf("必须指定矿工号")
+		}
+		group := cctx.String("group")
+		if _, err := reportBucket(time.Now(), group); err != nil {
+			fmt.Println(err)
+			return err
+		}
+		format := cctx.String("format")
+		if format != "table" && format != "csv" && format != "json" {
+			fmt.Printf("不支持的输出格式 %s，可选: table, csv, json\n", format)
+			return xerrors.Errorf("unknown format %s", format)
+		}
+
+		api, nCloser, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer nCloser()
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.Args().First())
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.Args().First(), err)
+			return err
+		}
+
+		ts, err := LoadTipSet(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+		// 错误和未证明的扇区同样会到期并释放质押，按全部存活扇区统计
+		sis, err := minerLiveSectors(ctx, api, maddr, ts)
+		if err != nil {
+			fmt.Printf("读取矿工(%s)扇区失败。 %v\n", maddr, err)
+			return err
+		}
+
+		// 按当前 tipset 的时间戳推算到期时间
+		headTime := time.Unix(int64(ts.MinTimestamp()), 0)
+		rows := make([]sectorReportRow, 0, len(sis))
+		for _, si := range sis {
+			expireAt := headTime.Add(time.Duration(si.Expiration-ts.Height()) * time.Duration(build.BlockDelaySecs) * time.Second)
+			row, err := newSectorReportRow(si, expireAt, group)
+			if err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+
+		report := &sectorReport{
+			Miner:  maddr.String(),
+			Height: ts.Height(),
+			Group:  group,
+		}
+		report.Buckets, report.Total = buildSectorBuckets(rows)
+		if cctx.Bool("sectors") {
+			report.Sectors = rows
+		}
+
+		w := io.Writer(os.Stdout)
+		if path := cctx.String("output"); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				fmt.Println(err)
+				return err
+			}
+			defer f.Close() //nolint:errcheck
+			w = f
+		}
+
+		switch format {
+		case "json":
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		case "csv":
+			return report.writeCSV(w, cctx.Bool("sectors"))
+		}
+		return report.writeTable(w, cctx.Bool("sectors"))
+	},
+}
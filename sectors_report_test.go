@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportBucket(t *testing.T) {
+	// 2024-05-01 是周三
+	tm := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	b, err := reportBucket(tm, reportGroupDay)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-01", b)
+
+	b, err = reportBucket(tm, reportGroupWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-04-29", b)
+
+	b, err = reportBucket(tm.AddDate(0, 0, 4), reportGroupWeek)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-04-29", b)
+
+	b, err = reportBucket(tm, reportGroupMonth)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05", b)
+
+	_, err = reportBucket(tm, "year")
+	assert.Error(t, err)
+}
+
+func TestBuildSectorBuckets(t *testing.T) {
+	row := func(n abi.SectorNumber, exp abi.ChainEpoch, bucket string, verified bool) sectorReportRow {
+		qap := abi.NewStoragePower(32)
+		if verified {
+			qap = abi.NewStoragePower(320)
+		}
+		return sectorReportRow{
+			Sector:        n,
+			Expiration:    exp,
+			Bucket:        bucket,
+			Verified:      verified,
+			RawPower:      abi.NewStoragePower(32),
+			QAPower:       qap,
+			InitialPledge: types.FIL(abi.NewTokenAmount(int64(n))),
+		}
+	}
+
+	buckets, total := buildSectorBuckets([]sectorReportRow{
+		row(3, 300, "2024-06", false),
+		row(1, 100, "2024-05", true),
+		row(2, 200, "2024-05", false),
+	})
+	assert.Len(t, buckets, 2)
+	assert.Equal(t, "2024-05", buckets[0].Bucket)
+	assert.Equal(t, 2, buckets[0].Sectors)
+	assert.Equal(t, 1, buckets[0].VerifiedSectors)
+	assert.Equal(t, abi.NewStoragePower(64), buckets[0].RawPower)
+	assert.Equal(t, abi.NewStoragePower(352), buckets[0].QAPower)
+	assert.Equal(t, types.FIL(abi.NewTokenAmount(3)), buckets[0].InitialPledge)
+
+	assert.Equal(t, 3, total.Sectors)
+	assert.Equal(t, abi.NewStoragePower(384), total.QAPower)
+	assert.Equal(t, types.FIL(abi.NewTokenAmount(6)), total.InitialPledge)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	mbuildin "github.com/filecoin-project/go-state-types/builtin"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	miner5 "github.com/filecoin-project/specs-actors/v5/actors/builtin/miner"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// readSectorFile 读取扇区编号文件，每行第一列为扇区编号，兼容 sectors expired 导出的文件
+func readSectorFile(path string) ([]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var out []uint64
+	sc := bufio.NewScanner(f)
+	first := true
+	for sc.Scan() {
+		fields := strings.FieldsFunc(sc.Text(), func(r rune) bool {
+			return r == ',' || r == ';' || r == ' ' || r == '\t'
+		})
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			// 第一行可以是表头
+			if first {
+				first = false
+				continue
+			}
+			return nil, xerrors.Errorf("扇区编号 %q 不正确: %w", fields[0], err)
+		}
+		first = false
+		out = append(out, n)
+	}
+	return out, sc.Err()
+}
+
+// splitTerminations 按扇区所在的deadline/partition组织终止声明，并按每条消息的声明数和扇区数上限拆分
+func splitTerminations(sectors map[miner.SectorLocation][]uint64, declMax, sectorsMax int) []miner5.TerminateSectorsParams {
+	locs := make([]miner.SectorLocation, 0, len(sectors))
+	for l := range sectors {
+		locs = append(locs, l)
+	}
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].Deadline != locs[j].Deadline {
+			return locs[i].Deadline < locs[j].Deadline
+		}
+		return locs[i].Partition < locs[j].Partition
+	})
+
+	var out []miner5.TerminateSectorsParams
+	cur := miner5.TerminateSectorsParams{}
+	count := 0
+	for _, l := range locs {
+		numbers := sectors[l]
+		sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+
+		for len(numbers) > 0 {
+			if count == sectorsMax || len(cur.Terminations) == declMax {
+				out = append(out, cur)
+				cur = miner5.TerminateSectorsParams{}
+				count = 0
+			}
+
+			n := len(numbers)
+			if n > sectorsMax-count {
+				n = sectorsMax - count
+			}
+			cur.Terminations = append(cur.Terminations, miner5.TerminationDeclaration{
+				Deadline:  l.Deadline,
+				Partition: l.Partition,
+				Sectors:   bitfield.NewFromSet(numbers[:n]),
+			})
+			count += n
+			numbers = numbers[n:]
+		}
+	}
+	if count > 0 {
+		out = append(out, cur)
+	}
+	return out
+}
+
+// terminationPenalty 模拟执行终止消息，统计转给销毁地址的金额作为罚金估算
+func terminationPenalty(ctx context.Context, api v0api.FullNode, msg *types.Message) (abi.TokenAmount, error) {
+	res, err := api.StateCall(ctx, msg, types.EmptyTSK)
+	if err != nil {
+		return big.Zero(), xerrors.Errorf("StateCall error: %w", err)
+	}
+	if res.MsgRct.ExitCode.IsError() {
+		return big.Zero(), xerrors.Errorf("模拟执行失败: exit code %d, %s", res.MsgRct.ExitCode, res.Error)
+	}
+
+	var sum func(trace []types.ExecutionTrace) abi.TokenAmount
+	sum = func(trace []types.ExecutionTrace) abi.TokenAmount {
+		total := big.Zero()
+		for _, t := range trace {
+			if t.Msg.To == mbuildin.BurntFundsActorAddr {
+				total = big.Add(total, t.Msg.Value)
+			}
+			total = big.Add(total, sum(t.Subcalls))
+		}
+		return total
+	}
+	return sum(res.ExecutionTrace.Subcalls), nil
+}
+
+// minerControlSender 选择本地钱包中持有的worker、owner或控制地址发送矿工消息
+func minerControlSender(ctx context.Context, api v0api.FullNode, maddr address.Address, role string) (address.Address, error) {
+	mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return address.Undef, xerrors.Errorf("读取矿工信息失败: %w", err)
+	}
+
+	var candidates []address.Address
+	switch role {
+	case "":
+		candidates = append([]address.Address{mi.Worker, mi.Owner}, mi.ControlAddresses...)
+	case "worker":
+		candidates = []address.Address{mi.Worker}
+	case "owner":
+		candidates = []address.Address{mi.Owner}
+	default:
+		return address.Undef, xerrors.Errorf("--sender 的值 %s 不正确，可选: worker, owner", role)
+	}
+
+	for _, c := range candidates {
+		key, err := api.StateAccountKey(ctx, c, types.EmptyTSK)
+		if err != nil {
+			continue
+		}
+		if _, err := localdb.Get(db.KeyAddr, key.String()); err == nil {
+			return key, nil
+		}
+	}
+	return address.Undef, xerrors.Errorf("本地钱包中没有矿工 %s 的worker、owner或控制地址", maddr)
+}
+
+var sectorsTerminateCmd = &cli.Command{
+	Name:      "terminate",
+	Usage:     "终止扇区，发送前估算终止罚金",
+	ArgsUsage: "<sectorNumbers...>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "miner",
+			Usage:    "miner id .eg(f02420)",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "from-file",
+			Usage: "从文件读取扇区编号，每行第一列为扇区编号，可以使用 sectors expired 导出的文件",
+		},
+		&cli.StringFlag{
+			Name:  "sender",
+			Usage: "发送消息的地址: worker、owner，默认选择本地钱包中持有的worker、owner或控制地址",
+		},
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		var numbers []uint64
+		for _, s := range cctx.Args().Slice() {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				fmt.Printf("扇区编号 %s 不正确\n", s)
+				return xerrors.Errorf("could not parse sector %s: %w", s, err)
+			}
+			numbers = append(numbers, n)
+		}
+		if path := cctx.String("from-file"); path != "" {
+			fromFile, err := readSectorFile(path)
+			if err != nil {
+				fmt.Println("读取扇区文件失败,", err)
+				return err
+			}
+			numbers = append(numbers, fromFile...)
+		}
+		if len(numbers) == 0 {
+			fmt.Println("必须指定要终止的扇区")
+			return fmt.Errorf("必须指定要终止的扇区")
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := parseAddress(cctx.String("miner"))
+		if err != nil {
+			fmt.Printf("输入miner ID(%s)不正确。 %v\n", cctx.String("miner"), err)
+			return err
+		}
+
+		sender, err := minerControlSender(ctx, api, maddr, cctx.String("sender"))
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		seen := map[uint64]bool{}
+		sectors := map[miner.SectorLocation][]uint64{}
+		for _, n := range numbers {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+
+			p, err := api.StateSectorPartition(ctx, maddr, abi.SectorNumber(n), types.EmptyTSK)
+			if err != nil {
+				fmt.Printf("查询扇区 %d 所在分区失败, %v\n", n, err)
+				return xerrors.Errorf("getting sector location for sector %d: %w", n, err)
+			}
+			if p == nil {
+				fmt.Printf("扇区 %d 不在任何分区中\n", n)
+				return xerrors.Errorf("sector %d not found in any partition", n)
+			}
+			sectors[*p] = append(sectors[*p], n)
+		}
+
+		nv, err := api.StateNetworkVersion(ctx, types.EmptyTSK)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		declMax, err := policy.GetDeclarationsMax(nv)
+		if err != nil {
+			return xerrors.Errorf("failed to get declarations max: %w", err)
+		}
+		sectorsMax, err := policy.GetAddressedSectorsMax(nv)
+		if err != nil {
+			return xerrors.Errorf("failed to get addressed sectors max: %w", err)
+		}
+		params := splitTerminations(sectors, declMax, sectorsMax)
+
+		var msgs []*types.Message
+		tw := tablewriter.New(
+			tablewriter.Col("Message"),
+			tablewriter.Col("Sectors"),
+			tablewriter.Col("Partitions"),
+			tablewriter.Col("Penalty"),
+			tablewriter.NewLineCol("Error"))
+		total := big.Zero()
+		failed := 0
+		for i := range params {
+			sp, aerr := actors.SerializeParams(&params[i])
+			if aerr != nil {
+				return xerrors.Errorf("serializing params: %w", aerr)
+			}
+			msg := &types.Message{
+				From:   sender,
+				To:     maddr,
+				Method: mbuildin.MethodsMiner.TerminateSectors,
+				Value:  big.Zero(),
+				Params: sp,
+			}
+			msgs = append(msgs, msg)
+
+			count := 0
+			for _, t := range params[i].Terminations {
+				c, _ := t.Sectors.Count()
+				count += int(c)
+			}
+			row := map[string]interface{}{
+				"Message":    i + 1,
+				"Sectors":    count,
+				"Partitions": len(params[i].Terminations),
+			}
+			// 前面的消息会改变矿工状态，罚金是各条消息单独执行时的估算
+			penalty, err := terminationPenalty(ctx, api, msg)
+			if err != nil {
+				row["Error"] = err.Error()
+				failed++
+			} else {
+				row["Penalty"] = types.FIL(penalty)
+				total = big.Add(total, penalty)
+			}
+			tw.Write(row)
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+		fmt.Printf("\n矿工 %s 终止 %d 个扇区，共 %d 条消息，估算罚金合计 %s，由 %s%s 发送\n", maddr, len(seen), len(msgs), types.FIL(total), sender, addressLabel(sender))
+
+		if failed > 0 {
+			return xerrors.Errorf("%d 条消息模拟执行失败", failed)
+		}
+		if dryRun {
+			return nil
+		}
+
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+
+		for i, msg := range msgs {
+			c, err := pushMessage(ctx, cctx, api, msg)
+			if err != nil {
+				fmt.Printf("第 %d 条消息发送失败，err:%v\n", i+1, err)
+				return err
+			}
+			fmt.Printf("第 %d 条消息: %s\n", i+1, c)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadSectorFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sectors.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("扇区编号\t过期高度\n3\t100\n\n1,200\n7\n"), 0644))
+
+	numbers, err := readSectorFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint64{3, 1, 7}, numbers)
+
+	assert.NoError(t, os.WriteFile(path, []byte("1\nabc\n"), 0644))
+	_, err = readSectorFile(path)
+	assert.Error(t, err)
+}
+
+func TestSplitTerminations(t *testing.T) {
+	sectors := map[miner.SectorLocation][]uint64{
+		{Deadline: 2, Partition: 0}: {9, 8},
+		{Deadline: 0, Partition: 1}: {1, 2, 3, 4, 5},
+		{Deadline: 0, Partition: 0}: {6},
+	}
+
+	params := splitTerminations(sectors, 2, 4)
+	assert.Len(t, params, 2)
+
+	counts := func(i int) []uint64 {
+		var out []uint64
+		for _, d := range params[i].Terminations {
+			c, err := d.Sectors.Count()
+			assert.NoError(t, err)
+			out = append(out, c)
+		}
+		return out
+	}
+	// 分区 0/1 被拆到两条消息中
+	assert.Equal(t, []uint64{1, 3}, counts(0))
+	assert.Equal(t, uint64(1), params[0].Terminations[1].Partition)
+	assert.Equal(t, []uint64{2, 2}, counts(1))
+	assert.Equal(t, uint64(2), params[1].Terminations[1].Deadline)
+
+	// 声明数上限
+	params = splitTerminations(sectors, 1, 100)
+	assert.Len(t, params, 3)
+}
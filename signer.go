@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/firefly-wallet/impl"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"golang.org/x/xerrors"
+)
+
+// 远程签名钱包的api信息，格式与 FULLNODE_API_INFO 相同: token:multiaddr
+const remoteWalletENV = "REMOTE_WALLET_API_INFO"
+
+var signers *impl.SignerRegistry
+var signerCloser jsonrpc.ClientCloser
+
+// loadSigners 根据本地数据库中的地址记录构造签名registry，
+// 本地地址优先，配置了 REMOTE_WALLET_API_INFO 时远程钱包的地址作为补充
+func loadSigners(ctx context.Context) (*impl.SignerRegistry, error) {
+	addrs, err := localdb.GetAll(db.KeyAddr)
+	if err != nil {
+		return nil, xerrors.Errorf("读取数据库获取钱包地址失败: %w", err)
+	}
+
+	hd := impl.NewHDSigner(string(localMnenoic))
+	imported := impl.NewImportedKeySigner(passwd)
+	for _, a := range addrs {
+		fai := FilAddressInfo{}
+		if err := json.Unmarshal([]byte(a), &fai); err != nil {
+			// usdt地址也保存在KeyAddr下，不是json
+			continue
+		}
+
+		addr, err := address.NewFromString(fai.Address)
+		if err != nil {
+			continue
+		}
+
+		if fai.Index == unRecoverIndex {
+			encrypted, err := localdb.Get(db.KeyPriKey, fai.Address)
+			if err != nil {
+				fmt.Printf("从数据库读取导入钱包(%s)失败！,err: %v\n", fai.Address, err)
+				continue
+			}
+			imported.Add(addr, encrypted)
+		} else {
+			hd.Add(addr, fai.Index)
+		}
+	}
+
+	registry := impl.NewSignerRegistry()
+	if err := registry.Register(ctx, hd); err != nil {
+		return nil, err
+	}
+	if err := registry.Register(ctx, imported); err != nil {
+		return nil, err
+	}
+
+	if apiInfo := os.Getenv(remoteWalletENV); apiInfo != "" {
+		remote, closer, err := impl.DialRemoteSigner(ctx, apiInfo)
+		if err != nil {
+			return nil, err
+		}
+		signerCloser = closer
+
+		if err := registry.Register(ctx, remote); err != nil {
+			return nil, xerrors.Errorf("加载远程钱包地址失败: %w", err)
+		}
+	}
+
+	return registry, nil
+}
+
+// registerHDAddress 把新派生的地址登记到当前的签名registry，使其在本次运行中即可签名
+func registerHDAddress(addr string, index int) {
+	if signers == nil {
+		return
+	}
+
+	a, err := address.NewFromString(addr)
+	if err != nil {
+		return
+	}
+
+	hd := impl.NewHDSigner(string(localMnenoic))
+	hd.Add(a, index)
+	if err := signers.Register(context.TODO(), hd); err != nil {
+		fmt.Printf("登记钱包地址(%s)到签名模块失败，err: %v\n", addr, err)
+	}
+}
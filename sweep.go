@@ -0,0 +1,329 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// sweepFilter 按矿工、地址类型、密钥类型、标签筛选本地钱包地址，空值表示不限制
+type sweepFilter struct {
+	Miner   string
+	Type    string
+	KeyType string
+	Tag     string
+}
+
+func (f *sweepFilter) match(fai *FilAddressInfo, addr address.Address) bool {
+	if f.Miner != "" && fai.MinerId != f.Miner {
+		return false
+	}
+	if f.Type != "" && fai.AddrType != f.Type {
+		return false
+	}
+	if f.Tag != "" && fai.Tag != f.Tag {
+		return false
+	}
+	if f.KeyType != "" && keyTypeName(addr) != f.KeyType {
+		return false
+	}
+	return true
+}
+
+func keyTypeName(addr address.Address) string {
+	switch addr.Protocol() {
+	case address.SECP256K1:
+		return "secp256k1"
+	case address.BLS:
+		return "bls"
+	case address.Delegated:
+		return "delegated"
+	}
+	return "unknown"
+}
+
+// sweepItem 一个要归集的地址，Msg 为估算好gas的转账消息
+type sweepItem struct {
+	Info    FilAddressInfo
+	From    address.Address
+	Balance abi.TokenAmount
+	Nonce   uint64
+	Msg     *types.Message
+	Note    string
+}
+
+// sweepAmount 扣除最高手续费后可以转出的金额
+func sweepAmount(balance abi.TokenAmount, msg *types.Message) abi.TokenAmount {
+	amount := big.Sub(balance, worstCaseFee(msg))
+	if amount.LessThan(big.Zero()) {
+		return big.Zero()
+	}
+	return amount
+}
+
+// localAddresses 读取本地钱包中符合条件的地址，按地址排序
+func localAddresses(f *sweepFilter) ([]FilAddressInfo, error) {
+	all, err := localdb.GetAll(db.KeyAddr)
+	if err != nil {
+		return nil, xerrors.Errorf("读取数据库获取钱包地址失败: %w", err)
+	}
+
+	var out []FilAddressInfo
+	for _, v := range all {
+		var fai FilAddressInfo
+		if err := json.Unmarshal([]byte(v), &fai); err != nil {
+			continue
+		}
+		addr, err := parseAddress(fai.Address)
+		if err != nil {
+			continue
+		}
+		if f.match(&fai, addr) {
+			out = append(out, fai)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Address < out[j].Address })
+	return out, nil
+}
+
+var sweepCmd = &cli.Command{
+	Name:      "sweep",
+	Usage:     "把本地钱包中符合条件的地址的全部余额(扣除手续费)转到一个地址",
+	ArgsUsage: "<destination address>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "miner",
+			Usage: "只归集属于这个矿工的地址",
+		},
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "只归集这个类型的地址: owner、worker、post",
+		},
+		&cli.StringFlag{
+			Name:  "key-type",
+			Usage: "只归集这个密钥类型的地址: secp256k1、bls",
+		},
+		&cli.StringFlag{
+			Name:  "tag",
+			Usage: "只归集有这个标签的地址，标签在 batch-gen-key --tag 时设置",
+		},
+		&cli.StringFlag{
+			Name:  "min-balance",
+			Usage: "只归集余额不低于这个值的地址(FIL)",
+			Value: "0",
+		},
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		if cctx.NArg() != 1 {
+			fmt.Println("必须指定归集的目标地址")
+			return fmt.Errorf("必须指定归集的目标地址")
+		}
+		dest, err := parseAddress(cctx.Args().First())
+		if err != nil {
+			fmt.Println("目标地址不正确,", err)
+			return err
+		}
+
+		minBalance, err := types.ParseFIL(cctx.String("min-balance"))
+		if err != nil {
+			fmt.Println("min-balance 不正确,", err)
+			return err
+		}
+
+		infos, err := localAddresses(&sweepFilter{
+			Miner:   cctx.String("miner"),
+			Type:    cctx.String("type"),
+			KeyType: cctx.String("key-type"),
+			Tag:     cctx.String("tag"),
+		})
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		// 每个地址按自己的密钥类型估算gas，bls和secp256k1签名的消息大小不同，gas也不同
+		var items []*sweepItem
+		for _, fai := range infos {
+			from, _ := parseAddress(fai.Address)
+			if from == dest {
+				continue
+			}
+
+			it := &sweepItem{Info: fai, From: from, Balance: big.Zero()}
+			items = append(items, it)
+
+			act, err := api.StateGetActor(ctx, from, types.EmptyTSK)
+			if err != nil {
+				it.Note = "没有余额"
+				continue
+			}
+			it.Balance = act.Balance
+			if it.Balance.IsZero() || it.Balance.LessThan(abi.TokenAmount(minBalance)) {
+				it.Note = "低于 min-balance"
+				continue
+			}
+
+			// 有待上链的消息时余额还会变化，不能算出准确的金额
+			pending, err := api.MpoolGetNonce(ctx, from)
+			if err != nil {
+				it.Note = fmt.Sprintf("读取nonce失败: %v", err)
+				continue
+			}
+			if pending != act.Nonce {
+				it.Note = fmt.Sprintf("有 %d 条待上链消息", pending-act.Nonce)
+				continue
+			}
+			it.Nonce = act.Nonce
+
+			msg, err := estimateMessageGas(ctx, cctx, api, &types.Message{
+				From:  from,
+				To:    dest,
+				Value: big.Zero(),
+				Nonce: act.Nonce,
+			})
+			if err != nil {
+				it.Note = fmt.Sprintf("评估gas失败: %v", err)
+				continue
+			}
+			msg.Value = sweepAmount(it.Balance, msg)
+			if msg.Value.IsZero() {
+				it.Note = "余额不足以支付手续费"
+				continue
+			}
+			it.Msg = msg
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("Address"),
+			tablewriter.Col("Key"),
+			tablewriter.Col("Miner"),
+			tablewriter.Col("Type"),
+			tablewriter.Col("Balance"),
+			tablewriter.Col("GasLimit"),
+			tablewriter.Col("MaxFee"),
+			tablewriter.Col("Amount"),
+			tablewriter.NewLineCol("Note"))
+
+		total := big.Zero()
+		var todo []*sweepItem
+		for _, it := range items {
+			row := map[string]interface{}{
+				"Address": it.From,
+				"Key":     keyTypeName(it.From),
+				"Miner":   it.Info.MinerId,
+				"Type":    it.Info.AddrType,
+				"Balance": types.FIL(it.Balance),
+				"Note":    it.Note,
+			}
+			if it.Msg != nil {
+				row["GasLimit"] = it.Msg.GasLimit
+				row["MaxFee"] = types.FIL(worstCaseFee(it.Msg))
+				row["Amount"] = types.FIL(it.Msg.Value)
+				total = big.Add(total, it.Msg.Value)
+				todo = append(todo, it)
+			}
+			tw.Write(row)
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Printf("\n共 %d 个地址，%d 个需要归集，合计 %s 转到 %s%s\n", len(items), len(todo), types.FIL(total), dest, addressLabel(dest))
+		if len(todo) == 0 {
+			return nil
+		}
+
+		if dryRun {
+			failed := 0
+			for _, it := range todo {
+				fmt.Printf("\n%s:\n", it.From)
+				if err := simulateMessage(ctx, api, it.Msg); err != nil {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return xerrors.Errorf("%d 条消息模拟执行失败", failed)
+			}
+			return nil
+		}
+
+		if err := confirmSend(cctx); err != nil {
+			if err == errCanceled {
+				return nil
+			}
+			return err
+		}
+
+		failed := 0
+		for _, it := range todo {
+			// 通过nonce管理器占用nonce，和预览时的nonce不一致说明期间有其它消息
+			nonce, err := reserveNonce(ctx, api, it.From)
+			if err != nil {
+				fmt.Printf("%s 读取nonce失败，err:%v\n", it.From, err)
+				failed++
+				continue
+			}
+			if nonce != it.Nonce {
+				releaseNonce(it.From, nonce)
+				fmt.Printf("%s 的nonce已经变化(%d -> %d)，跳过\n", it.From, it.Nonce, nonce)
+				failed++
+				continue
+			}
+
+			sb, err := signMessage(it.Msg.Cid().Bytes(), it.From)
+			if err != nil {
+				releaseNonce(it.From, nonce)
+				fmt.Printf("%s 签名失败，err:%v\n", it.From, err)
+				failed++
+				continue
+			}
+
+			smsg := &types.SignedMessage{Message: *it.Msg, Signature: *sb}
+			c, err := api.MpoolPush(ctx, smsg)
+			if err != nil {
+				releaseNonce(it.From, nonce)
+				fmt.Printf("%s 推送消息失败，err:%v\n", it.From, err)
+				failed++
+				continue
+			}
+			recordMessage(cctx, smsg, cid.Undef)
+			fmt.Printf("%s -> %s %s, Message CID: %s\n", it.From, dest, types.FIL(it.Msg.Value), c)
+		}
+
+		if failed > 0 {
+			fmt.Printf("%d 个地址归集失败\n", failed)
+			return xerrors.Errorf("%d 个地址归集失败", failed)
+		}
+		return nil
+	},
+}
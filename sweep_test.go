@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweepFilter(t *testing.T) {
+	addr, err := address.NewIDAddress(1000)
+	assert.NoError(t, err)
+	fai := &FilAddressInfo{MinerId: "f01234", AddrType: "worker", Tag: "batch1"}
+
+	assert.True(t, (&sweepFilter{}).match(fai, addr))
+	assert.True(t, (&sweepFilter{Miner: "f01234", Type: "worker", Tag: "batch1"}).match(fai, addr))
+	assert.False(t, (&sweepFilter{Miner: "f05678"}).match(fai, addr))
+	assert.False(t, (&sweepFilter{Tag: "batch2"}).match(fai, addr))
+	assert.False(t, (&sweepFilter{KeyType: "bls"}).match(fai, addr))
+}
+
+func TestSweepAmount(t *testing.T) {
+	msg := &types.Message{GasLimit: 1000, GasFeeCap: abi.NewTokenAmount(100)}
+
+	assert.Equal(t, abi.NewTokenAmount(400000), sweepAmount(abi.NewTokenAmount(500000), msg))
+	assert.Equal(t, big.Zero(), sweepAmount(abi.NewTokenAmount(50000), msg))
+}
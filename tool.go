@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/lotus/chain/types"
 	lcli "github.com/filecoin-project/lotus/cli"
 	"github.com/urfave/cli/v2"
@@ -26,7 +25,7 @@ var listPostCmd = &cli.Command{
 	Flags:     []cli.Flag{},
 	Action: func(cctx *cli.Context) error {
 
-		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		api, acloser, err := getFullNodeAPI(cctx)
 		if err != nil {
 			fmt.Println(err)
 			return err
@@ -51,7 +50,7 @@ var listPostCmd = &cli.Command{
 			if len(miner) < 3 {
 				continue
 			}
-			maddr, err := address.NewFromString(miner)
+			maddr, err := parseAddress(miner)
 			if err != nil {
 				fmt.Println(err)
 				continue
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 资金策略类型
+const (
+	policyRefill = "refill" // 保持 Target 的余额在 Min 和 Max 之间，低于 Min 时从 Source 补充到 Max
+	policySweep  = "sweep"  // 把 Sources 和 Miners 的 worker/control 地址中超过 Keep 的余额转到 Dest
+)
+
+// treasuryPolicy 资金策略，保存在 config.json 的 Treasury 中，金额单位为FIL
+type treasuryPolicy struct {
+	Name string
+	Type string
+
+	Target string `json:",omitempty"`
+	Min    string `json:",omitempty"`
+	Max    string `json:",omitempty"`
+	Source string `json:",omitempty"`
+
+	Sources []string `json:",omitempty"`
+	Miners  []string `json:",omitempty"`
+	Keep    string   `json:",omitempty"`
+	Dest    string   `json:",omitempty"`
+}
+
+// check 检查策略的参数
+func (p *treasuryPolicy) check() error {
+	if p.Name == "" {
+		return xerrors.Errorf("策略必须有名字")
+	}
+
+	parse := func(field, s string) (abi.TokenAmount, error) {
+		v, err := types.ParseFIL(s)
+		if err != nil {
+			return big.Zero(), xerrors.Errorf("策略 %s 的 %s 不正确: %w", p.Name, field, err)
+		}
+		return abi.TokenAmount(v), nil
+	}
+
+	switch p.Type {
+	case policyRefill:
+		if p.Target == "" || p.Source == "" {
+			return xerrors.Errorf("策略 %s 必须指定 Target 和 Source", p.Name)
+		}
+		min, err := parse("Min", p.Min)
+		if err != nil {
+			return err
+		}
+		max, err := parse("Max", p.Max)
+		if err != nil {
+			return err
+		}
+		if max.LessThan(min) {
+			return xerrors.Errorf("策略 %s 的 Max 不能小于 Min", p.Name)
+		}
+	case policySweep:
+		if p.Dest == "" || len(p.Sources)+len(p.Miners) == 0 {
+			return xerrors.Errorf("策略 %s 必须指定 Dest 以及 Sources 或 Miners", p.Name)
+		}
+		if _, err := parse("Keep", p.Keep); err != nil {
+			return err
+		}
+	default:
+		return xerrors.Errorf("策略 %s 的类型 %q 不正确，可选: refill, sweep", p.Name, p.Type)
+	}
+	return nil
+}
+
+func (p *treasuryPolicy) String() string {
+	switch p.Type {
+	case policyRefill:
+		return fmt.Sprintf("保持 %s 的余额在 %s 到 %s FIL 之间，从 %s 补充", p.Target, p.Min, p.Max, p.Source)
+	case policySweep:
+		return fmt.Sprintf("把 %v 以及矿工 %v 的worker/control地址中超过 %s FIL 的余额转到 %s", p.Sources, p.Miners, p.Keep, p.Dest)
+	}
+	return p.Type
+}
+
+// treasuryRunner 按顺序执行资金策略。前面的策略转入或转出某个地址的消息确认后，才会从这个地址转出或读取它的余额
+type treasuryRunner struct {
+	ctx  context.Context
+	cctx *cli.Context
+	api  v0api.FullNode
+
+	// 本次执行中转入或转出地址、还没有确认的消息
+	pending map[address.Address][]cid.Cid
+	// 已经确认的消息，同一条消息只等待一次
+	confirmed map[cid.Cid]bool
+	// --dry-run 时不会真正转账，记录模拟的余额变化
+	delta map[address.Address]abi.TokenAmount
+}
+
+func newTreasuryRunner(ctx context.Context, cctx *cli.Context, api v0api.FullNode) *treasuryRunner {
+	return &treasuryRunner{
+		ctx:       ctx,
+		cctx:      cctx,
+		api:       api,
+		pending:   map[address.Address][]cid.Cid{},
+		confirmed: map[cid.Cid]bool{},
+		delta:     map[address.Address]abi.TokenAmount{},
+	}
+}
+
+// balance 等待转入和转出addr的消息确认后读取余额
+func (r *treasuryRunner) balance(addr address.Address) (abi.TokenAmount, error) {
+	for _, c := range r.pending[addr] {
+		if r.confirmed[c] {
+			continue
+		}
+		if _, err := waitMessage(r.ctx, r.cctx, r.api, c); err != nil {
+			return big.Zero(), xerrors.Errorf("等待 %s 的转账消息 %s 失败: %w", addr, c, err)
+		}
+		r.confirmed[c] = true
+	}
+	delete(r.pending, addr)
+
+	b, err := r.api.WalletBalance(r.ctx, addr)
+	if err != nil {
+		return big.Zero(), err
+	}
+	if d, ok := r.delta[addr]; ok {
+		b = big.Add(b, d)
+	}
+	return b, nil
+}
+
+func (r *treasuryRunner) addDelta(addr address.Address, v abi.TokenAmount) {
+	if d, ok := r.delta[addr]; ok {
+		v = big.Add(d, v)
+	}
+	r.delta[addr] = v
+}
+
+func (r *treasuryRunner) transfer(from, to address.Address, amount abi.TokenAmount) error {
+	fmt.Printf("转账 %s%s -> %s%s %s\n", from, addressLabel(from), to, addressLabel(to), types.FIL(amount))
+
+	if dryRun {
+		if _, err := send(r.cctx, r.api, from, to.String(), types.FIL(amount).Unitless(), nil); err != nil {
+			return err
+		}
+		r.addDelta(from, big.Sub(big.Zero(), amount))
+		r.addDelta(to, amount)
+		return nil
+	}
+
+	if err := confirmSend(r.cctx); err != nil {
+		if err == errCanceled {
+			return nil
+		}
+		return err
+	}
+
+	c, err := send(r.cctx, r.api, from, to.String(), types.FIL(amount).Unitless(), nil)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Message CID:", c)
+	r.pending[from] = append(r.pending[from], c)
+	r.pending[to] = append(r.pending[to], c)
+	return nil
+}
+
+func (r *treasuryRunner) run(p *treasuryPolicy) error {
+	switch p.Type {
+	case policyRefill:
+		return r.refill(p)
+	case policySweep:
+		return r.sweep(p)
+	}
+	return xerrors.Errorf("unknown policy type %s", p.Type)
+}
+
+func (r *treasuryRunner) refill(p *treasuryPolicy) error {
+	target, err := parseAddress(p.Target)
+	if err != nil {
+		return err
+	}
+	source, err := parseAddress(p.Source)
+	if err != nil {
+		return err
+	}
+	min, _ := types.ParseFIL(p.Min)
+	max, _ := types.ParseFIL(p.Max)
+
+	tb, err := r.balance(target)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s 余额 %s\n", target, types.FIL(tb))
+	if !tb.LessThan(abi.TokenAmount(min)) {
+		fmt.Println("余额不低于下限，不需要补充")
+		return nil
+	}
+
+	amount := big.Sub(abi.TokenAmount(max), tb)
+
+	sb, err := r.balance(source)
+	if err != nil {
+		return err
+	}
+	fee, err := estimateTransferFee(r.ctx, r.cctx, r.api, source, target)
+	if err != nil {
+		return err
+	}
+	if avail := big.Sub(sb, fee); avail.LessThan(amount) {
+		if !avail.GreaterThan(big.Zero()) {
+			return xerrors.Errorf("%s 余额 %s 不足以补充", source, types.FIL(sb))
+		}
+		fmt.Printf("%s 余额 %s 不足以补充到 %s FIL，只转入 %s\n", source, types.FIL(sb), p.Max, types.FIL(avail))
+		amount = avail
+	}
+
+	return r.transfer(source, target, amount)
+}
+
+func (r *treasuryRunner) sweep(p *treasuryPolicy) error {
+	dest, err := parseAddress(p.Dest)
+	if err != nil {
+		return err
+	}
+	keep, _ := types.ParseFIL(p.Keep)
+
+	var sources []address.Address
+	for _, s := range p.Sources {
+		a, err := parseAddress(s)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, a)
+	}
+	for _, m := range p.Miners {
+		maddr, err := parseAddress(m)
+		if err != nil {
+			return err
+		}
+		mi, err := r.api.StateMinerInfo(r.ctx, maddr, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("读取矿工 %s 信息失败: %w", maddr, err)
+		}
+		for _, id := range append([]address.Address{mi.Worker}, mi.ControlAddresses...) {
+			key, err := r.api.StateAccountKey(r.ctx, id, types.EmptyTSK)
+			if err != nil {
+				return xerrors.Errorf("查询 %s 的公钥地址失败: %w", id, err)
+			}
+			sources = append(sources, key)
+		}
+	}
+
+	seen := map[address.Address]bool{}
+	for _, src := range sources {
+		if seen[src] || src == dest {
+			continue
+		}
+		seen[src] = true
+
+		b, err := r.balance(src)
+		if err != nil {
+			return err
+		}
+		fee, err := estimateTransferFee(r.ctx, r.cctx, r.api, src, dest)
+		if err != nil {
+			return err
+		}
+
+		amount := big.Sub(big.Sub(b, abi.TokenAmount(keep)), fee)
+		if !amount.GreaterThan(big.Zero()) {
+			fmt.Printf("%s 余额 %s，不需要归集\n", src, types.FIL(b))
+			continue
+		}
+		if err := r.transfer(src, dest, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// wait 等待本次执行中所有转账确认
+func (r *treasuryRunner) wait() error {
+	for addr := range r.pending {
+		if _, err := r.balance(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadTreasuryPolicies(names []string) ([]*treasuryPolicy, error) {
+	cfg, err := loadRepoConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range cfg.Treasury {
+		if err := p.check(); err != nil {
+			return nil, err
+		}
+	}
+	if len(names) == 0 {
+		return cfg.Treasury, nil
+	}
+
+	var policies []*treasuryPolicy
+	for _, name := range names {
+		found := false
+		for _, p := range cfg.Treasury {
+			if p.Name == name {
+				policies = append(policies, p)
+				found = true
+			}
+		}
+		if !found {
+			return nil, xerrors.Errorf("没有找到策略 %s", name)
+		}
+	}
+	return policies, nil
+}
+
+var treasuryCmd = &cli.Command{
+	Name:  "treasury",
+	Usage: "按 config.json 中的资金策略补充热钱包或归集余额",
+	Subcommands: []*cli.Command{
+		treasuryListCmd,
+		treasuryRunCmd,
+	},
+}
+
+var treasuryListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "列出配置的资金策略",
+	Action: func(cctx *cli.Context) error {
+		policies, err := loadTreasuryPolicies(nil)
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "Name\tType\tPolicy\n")
+		for _, p := range policies {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Type, p)
+		}
+		return w.Flush()
+	},
+}
+
+var treasuryRunCmd = &cli.Command{
+	Name:      "run",
+	Usage:     "按顺序执行资金策略，不指定名字时执行所有策略。前面策略的转账确认后才会执行依赖它的转账",
+	ArgsUsage: "[policy name...]",
+	Flags: []cli.Flag{
+		confidenceFlag,
+		waitTimeoutFlag,
+		maxFeeFlag,
+		gasPremiumFlag,
+		gasFeeCapFlag,
+		gasLimitFlag,
+		yesFlag,
+	},
+	Before: messageBefore,
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		policies, err := loadTreasuryPolicies(cctx.Args().Slice())
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+		if len(policies) == 0 {
+			fmt.Println("config.json 中没有配置资金策略")
+			return nil
+		}
+
+		api, closer, err := getFullNodeAPI(cctx)
+		if err != nil {
+			fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+			return err
+		}
+		defer closer()
+
+		r := newTreasuryRunner(lcli.ReqContext(cctx), cctx, api)
+		for _, p := range policies {
+			fmt.Printf("\n执行策略 %s: %s\n", p.Name, p)
+			if err := r.run(p); err != nil {
+				fmt.Printf("执行策略 %s 失败，err: %v\n", p.Name, err)
+				return err
+			}
+		}
+
+		if dryRun {
+			return nil
+		}
+		return r.wait()
+	},
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreasuryPolicyCheck(t *testing.T) {
+	refill := &treasuryPolicy{Name: "hot", Type: policyRefill, Target: "f1a", Source: "f1b", Min: "100", Max: "500"}
+	assert.NoError(t, refill.check())
+
+	refill.Max = "50"
+	assert.Error(t, refill.check())
+
+	refill.Max = "abc"
+	assert.Error(t, refill.check())
+
+	sweep := &treasuryPolicy{Name: "workers", Type: policySweep, Miners: []string{"f01234"}, Keep: "10", Dest: "f1z"}
+	assert.NoError(t, sweep.check())
+
+	sweep.Miners = nil
+	assert.Error(t, sweep.check())
+
+	assert.Error(t, (&treasuryPolicy{Name: "x", Type: "other"}).check())
+	assert.Error(t, (&treasuryPolicy{Type: policySweep}).check())
+}
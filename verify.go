@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/filecoin-project/firefly-wallet/impl"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/lotus/chain/consensus"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var verifyCmd = &cli.Command{
+	Name:      "verify",
+	Usage:     "验证签名，支持secp256k1、bls和delegated签名，也可以验证签名消息(--signed-message)",
+	ArgsUsage: "<signing address> <hexMessage> <hexSignature>",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "signed-message",
+			Usage: "验证签名消息文件(json或cbor)，- 表示从标准输入读取，签名者为消息的From",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.IsSet("signed-message") {
+			return verifySignedMessage(cctx, cctx.String("signed-message"))
+		}
+
+		if cctx.NArg() != 3 {
+			fmt.Println("必须指定签名钱包地址、签名的消息和签名")
+			return fmt.Errorf("必须指定签名钱包地址、签名的消息和签名")
+		}
+
+		addr, err := address.NewFromString(cctx.Args().First())
+		if err != nil {
+			fmt.Println("输入签名的钱包地址异常,", err)
+			return err
+		}
+
+		msg, err := hex.DecodeString(cctx.Args().Get(1))
+		if err != nil {
+			fmt.Println("解析签名的内容异常,", err)
+			return err
+		}
+
+		sigBytes, err := hex.DecodeString(cctx.Args().Get(2))
+		if err != nil {
+			fmt.Println("解析签名异常,", err)
+			return err
+		}
+
+		sig, err := impl.DecodeSignature(sigBytes)
+		if err != nil {
+			fmt.Println("解析签名异常,", err)
+			return err
+		}
+
+		if sig.Type == crypto.SigTypeSecp256k1 || sig.Type == crypto.SigTypeDelegated {
+			recovered, err := impl.RecoverSigner(sig, msg)
+			if err != nil {
+				fmt.Println("恢复签名者地址失败,", err)
+				return err
+			}
+			fmt.Println("签名者地址:", recovered)
+		}
+
+		signer, err := resolveSigner(cctx, addr)
+		if err != nil {
+			return err
+		}
+
+		if err := impl.VerifySignature(sig, signer, msg); err != nil {
+			fmt.Println("签名验证失败,", err)
+			return xerrors.Errorf("invalid signature: %w", err)
+		}
+
+		fmt.Println("签名验证通过")
+		return nil
+	},
+}
+
+// verifySignedMessage 验证签名消息的签名是否属于消息的From
+func verifySignedMessage(cctx *cli.Context, path string) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Printf("读取签名消息(%s)失败: %v\n", path, err)
+		return err
+	}
+
+	smsg, err := decodeSignedMessage(data)
+	if err != nil {
+		fmt.Println("解析签名消息失败,", err)
+		return err
+	}
+
+	signer, err := resolveSigner(cctx, smsg.Message.From)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("消息: %s\nFrom: %s (%s)\nTo: %s\nNonce: %d\nValue: %s\nMethod: %d\n",
+		smsg.Cid(), smsg.Message.From, signer, smsg.Message.To, smsg.Message.Nonce, types.FIL(smsg.Message.Value), smsg.Message.Method)
+
+	if err := consensus.AuthenticateMessage(smsg, signer); err != nil {
+		fmt.Println("签名验证失败,", err)
+		return err
+	}
+
+	fmt.Println("签名验证通过")
+	return nil
+}
+
+// decodeSignedMessage 支持lotus json格式、hex编码的cbor和原始cbor
+func decodeSignedMessage(data []byte) (*types.SignedMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var smsg types.SignedMessage
+		if err := json.Unmarshal(trimmed, &smsg); err != nil {
+			return nil, xerrors.Errorf("unmarshaling json: %w", err)
+		}
+		return &smsg, nil
+	}
+
+	if decoded, err := hex.DecodeString(strings.TrimPrefix(string(trimmed), "0x")); err == nil {
+		data = decoded
+	}
+	return types.DecodeSignedMessage(data)
+}
+
+// resolveSigner 签名是对公钥地址做的，ID地址需要通过节点查询对应的公钥地址
+func resolveSigner(cctx *cli.Context, addr address.Address) (address.Address, error) {
+	if addr.Protocol() != address.ID {
+		return addr, nil
+	}
+
+	api, closer, err := getFullNodeAPI(cctx)
+	if err != nil {
+		fmt.Printf("ID地址需要连接节点查询公钥地址，连接FULLNODE_API_INFO api失败。%v\n", err)
+		return address.Undef, err
+	}
+	defer closer()
+
+	key, err := api.StateAccountKey(lcli.ReqContext(cctx), addr, types.EmptyTSK)
+	if err != nil {
+		fmt.Printf("查询 %s 的公钥地址失败: %v\n", addr, err)
+		return address.Undef, err
+	}
+	return key, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/consensus"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+)
+
+var waitFlag = &cli.BoolFlag{
+	Name:  "wait",
+	Usage: "推送后等待消息上链，打印执行结果，消息执行失败时返回非0",
+}
+
+var confidenceFlag = &cli.IntFlag{
+	Name:  "confidence",
+	Usage: "等待消息上链后再确认的高度数",
+	Value: int(build.MessageConfidence),
+}
+
+var waitTimeoutFlag = &cli.DurationFlag{
+	Name:  "wait-timeout",
+	Usage: "等待消息上链的超时时间",
+	Value: 30 * time.Minute,
+}
+
+// waitMessage 等待消息上链并打印回执，超时时消息仍在消息池中等待打包
+func waitMessage(ctx context.Context, cctx *cli.Context, api v0api.FullNode, msgCid cid.Cid) (*lapi.MsgLookup, error) {
+	fmt.Printf("等待消息 %s 上链...\n", msgCid)
+
+	wctx, cancel := context.WithTimeout(ctx, cctx.Duration(waitTimeoutFlag.Name))
+	defer cancel()
+
+	lookup, err := api.StateWaitMsg(wctx, msgCid, uint64(cctx.Int(confidenceFlag.Name)))
+	if err != nil {
+		if wctx.Err() == context.DeadlineExceeded {
+			fmt.Printf("等待超时(%s)，消息 %s 仍在等待打包(pending)，稍后可以用 mpool 或区块浏览器查看\n", cctx.Duration(waitTimeoutFlag.Name), msgCid)
+			return nil, xerrors.Errorf("message %s still pending after %s", msgCid, cctx.Duration(waitTimeoutFlag.Name))
+		}
+		fmt.Printf("等待消息上链失败，err:%v\n", err)
+		return nil, err
+	}
+
+	printReceipt(ctx, api, lookup)
+
+	if lookup.Receipt.ExitCode.IsError() {
+		return lookup, xerrors.Errorf("message %s failed on chain: exit code %d", lookup.Message, lookup.Receipt.ExitCode)
+	}
+	return lookup, nil
+}
+
+func printReceipt(ctx context.Context, api v0api.FullNode, lookup *lapi.MsgLookup) {
+	fmt.Printf("消息已上链: %s\n", lookup.Message)
+	fmt.Printf("高度: %d\n", lookup.Height)
+	fmt.Printf("退出码: %d (%s)\n", lookup.Receipt.ExitCode, lookup.Receipt.ExitCode)
+	fmt.Printf("GasUsed: %d\n", lookup.Receipt.GasUsed)
+
+	res, err := api.StateReplay(ctx, types.EmptyTSK, lookup.Message)
+	if err != nil {
+		fmt.Printf("读取消息的手续费明细失败: %v\n", err)
+		return
+	}
+	fmt.Printf("BaseFeeBurn: %s\n", types.FIL(res.GasCost.BaseFeeBurn))
+	fmt.Printf("手续费合计: %s\n", types.FIL(res.GasCost.TotalCost))
+
+	if len(lookup.Receipt.Return) > 0 && res.Msg != nil {
+		fmt.Printf("返回值: %s\n", decodeReturn(ctx, api, res.Msg.To, res.Msg.Method, lookup.Receipt.Return))
+	}
+}
+
+// decodeReturn 按目标actor的方法定义解析返回值，无法解析时返回hex
+func decodeReturn(ctx context.Context, api v0api.FullNode, to address.Address, method abi.MethodNum, ret []byte) string {
+	raw := fmt.Sprintf("%x", ret)
+
+	act, err := api.StateGetActor(ctx, to, types.EmptyTSK)
+	if err != nil {
+		return raw
+	}
+	m, ok := consensus.NewActorRegistry().Methods[act.Code][method]
+	if !ok || m.Ret == nil || m.Ret.Kind() != reflect.Ptr {
+		return raw
+	}
+
+	rtyp, ok := reflect.New(m.Ret.Elem()).Interface().(cbg.CBORUnmarshaler)
+	if !ok {
+		return raw
+	}
+	if err := rtyp.UnmarshalCBOR(bytes.NewReader(ret)); err != nil {
+		return raw
+	}
+
+	b, err := json.Marshal(rtyp)
+	if err != nil {
+		return raw
+	}
+	return string(b)
+}
@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/firefly-wallet/impl"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/crypto"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+// 钱包api的jwt密钥保存在数据库的key
+const walletAPISecretKey = "walletApiSecret"
+
+type jwtPayload struct {
+	Allow []auth.Permission
+}
+
+// 每个方法默认需要的权限，可以通过 --perm 修改
+var defaultWalletAPIPerms = map[string]auth.Permission{
+	"WalletNew":    lapi.PermWrite,
+	"WalletHas":    lapi.PermRead,
+	"WalletList":   lapi.PermRead,
+	"WalletSign":   lapi.PermSign,
+	"WalletExport": lapi.PermAdmin,
+	"WalletImport": lapi.PermAdmin,
+	"WalletDelete": lapi.PermAdmin,
+}
+
+// walletAPI 在本地数据库上实现lotus的 api.Wallet 接口，供 lotus daemon --wallet-api 使用
+type walletAPI struct {
+	perms       map[string]auth.Permission
+	allowExport bool
+}
+
+var _ lapi.Wallet = (*walletAPI)(nil)
+
+func (w *walletAPI) checkPerm(ctx context.Context, method string) error {
+	perm := w.perms[method]
+	if !auth.HasPerm(ctx, nil, perm) {
+		return xerrors.Errorf("missing permission to invoke '%s' (need '%s')", method, perm)
+	}
+	return nil
+}
+
+func (w *walletAPI) WalletNew(ctx context.Context, kt types.KeyType) (address.Address, error) {
+	if err := w.checkPerm(ctx, "WalletNew"); err != nil {
+		return address.Undef, err
+	}
+
+	var bls bool
+	switch kt {
+	case types.KTBLS:
+		bls = true
+	case types.KTSecp256k1:
+	default:
+		return address.Undef, xerrors.Errorf("unsupported key type: %s", kt)
+	}
+
+	addr, _, err := createAddress(false, bls, "", "")
+	if err != nil {
+		return address.Undef, xerrors.Errorf("创建钱包地址失败: %w", err)
+	}
+	fmt.Printf("WalletNew: %s\n", addr)
+	return address.NewFromString(addr)
+}
+
+func (w *walletAPI) WalletHas(ctx context.Context, addr address.Address) (bool, error) {
+	if err := w.checkPerm(ctx, "WalletHas"); err != nil {
+		return false, err
+	}
+
+	_, err := localdb.Get(db.KeyAddr, addr.String())
+	if err == errors.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (w *walletAPI) WalletList(ctx context.Context) ([]address.Address, error) {
+	if err := w.checkPerm(ctx, "WalletList"); err != nil {
+		return nil, err
+	}
+
+	addrs, err := localdb.GetAll(db.KeyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]address.Address, 0, len(addrs))
+	for _, a := range addrs {
+		fai := FilAddressInfo{}
+		if err := json.Unmarshal([]byte(a), &fai); err != nil {
+			continue
+		}
+		addr, err := address.NewFromString(fai.Address)
+		if err != nil {
+			continue
+		}
+		out = append(out, addr)
+	}
+	return out, nil
+}
+
+func (w *walletAPI) WalletSign(ctx context.Context, signer address.Address, toSign []byte, meta lapi.MsgMeta) (*crypto.Signature, error) {
+	if err := w.checkPerm(ctx, "WalletSign"); err != nil {
+		return nil, err
+	}
+
+	has, err := w.WalletHas(auth.WithPerm(ctx, lapi.AllPermissions), signer)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, xerrors.Errorf("address %s not found in local wallet", signer)
+	}
+
+	fmt.Printf("WalletSign: %s type: %s\n", signer, meta.Type)
+	return signMessage(toSign, signer)
+}
+
+func (w *walletAPI) WalletExport(ctx context.Context, addr address.Address) (*types.KeyInfo, error) {
+	if err := w.checkPerm(ctx, "WalletExport"); err != nil {
+		return nil, err
+	}
+	if !w.allowExport {
+		return nil, xerrors.New("WalletExport is disabled, restart with --enable-export to allow it")
+	}
+
+	fmt.Printf("WalletExport: %s\n", addr)
+	privKey, err := exportPrivateKey(addr.String())
+	if err != nil {
+		return nil, err
+	}
+	return impl.ParseKeyInfo([]byte(privKey))
+}
+
+func (w *walletAPI) WalletImport(ctx context.Context, ki *types.KeyInfo) (address.Address, error) {
+	if err := w.checkPerm(ctx, "WalletImport"); err != nil {
+		return address.Undef, err
+	}
+	return address.Undef, xerrors.New("WalletImport is not supported, use the import command instead")
+}
+
+func (w *walletAPI) WalletDelete(ctx context.Context, addr address.Address) error {
+	if err := w.checkPerm(ctx, "WalletDelete"); err != nil {
+		return err
+	}
+
+	fmt.Printf("WalletDelete: %s\n", addr)
+	// 与lotus相同，地址和私钥移到trash下，需要时可以从数据库恢复
+	for _, k := range []struct{ from, to db.KeyType }{
+		{db.KeyPriKey, db.KeyTrashPriKey},
+		{db.KeyAddr, db.KeyTrashAddr},
+	} {
+		v, err := localdb.Get(k.from, addr.String())
+		if err == errors.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := localdb.Add(k.to, addr.String(), v); err != nil {
+			return xerrors.Errorf("备份%s失败: %w", addr, err)
+		}
+		if err := localdb.Del(k.from, addr.String()); err != nil {
+			return err
+		}
+	}
+
+	if signers != nil {
+		signers.Unregister(addr)
+	}
+	return nil
+}
+
+// walletAPISecret 读取钱包api的jwt密钥，第一次使用时生成
+func walletAPISecret() ([]byte, error) {
+	secret, err := localdb.Get(db.KeyCommon, walletAPISecretKey)
+	if err == nil {
+		return hex.DecodeString(string(secret))
+	}
+	if err != errors.ErrNotFound {
+		return nil, err
+	}
+
+	secret = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, secret); err != nil {
+		return nil, err
+	}
+	if err := localdb.Add(db.KeyCommon, walletAPISecretKey, []byte(hex.EncodeToString(secret))); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// parseWalletAPIPerms 解析 --perm 参数，格式: WalletSign=sign
+func parseWalletAPIPerms(overrides []string) (map[string]auth.Permission, error) {
+	perms := map[string]auth.Permission{}
+	for method, perm := range defaultWalletAPIPerms {
+		perms[method] = perm
+	}
+
+	for _, o := range overrides {
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) != 2 {
+			return nil, xerrors.Errorf("invalid permission setting %q, expected Method=perm", o)
+		}
+		if _, ok := perms[kv[0]]; !ok {
+			return nil, xerrors.Errorf("unknown wallet method %q", kv[0])
+		}
+		if !validPerm(auth.Permission(kv[1])) {
+			return nil, xerrors.Errorf("unknown permission %q", kv[1])
+		}
+		perms[kv[0]] = auth.Permission(kv[1])
+	}
+	return perms, nil
+}
+
+func validPerm(perm auth.Permission) bool {
+	for _, p := range lapi.AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+var serveWalletAPICmd = &cli.Command{
+	Name:  "serve-wallet-api",
+	Usage: "以lotus远程钱包api的方式提供签名服务，lotus daemon --wallet-api 指向本服务即可",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "listen",
+			Usage: "钱包api监听的地址和端口",
+			Value: "127.0.0.1:1777",
+		},
+		&cli.BoolFlag{
+			Name:  "enable-export",
+			Usage: "允许通过 WalletExport 导出私钥",
+			Value: false,
+		},
+		&cli.StringSliceFlag{
+			Name:  "perm",
+			Usage: "修改方法需要的权限(read, write, sign, admin)，例如 --perm WalletList=sign",
+		},
+	},
+	Subcommands: []*cli.Command{
+		walletAPITokenCmd,
+	},
+	Before: func(context *cli.Context) error {
+		if err := _init(); err != nil {
+			passwdValid = false
+		}
+		return nil
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		perms, err := parseWalletAPIPerms(cctx.StringSlice("perm"))
+		if err != nil {
+			fmt.Println(err)
+			return err
+		}
+
+		secret, err := walletAPISecret()
+		if err != nil {
+			fmt.Printf("读取钱包api密钥失败，err: %v\n", err)
+			return err
+		}
+
+		rpcServer := jsonrpc.NewServer(jsonrpc.WithServerErrors(lapi.RPCErrors))
+		rpcServer.Register("Filecoin", &walletAPI{perms: perms, allowExport: cctx.Bool("enable-export")})
+
+		mux := http.NewServeMux()
+		mux.Handle("/rpc/v0", rpcServer)
+
+		handler := &auth.Handler{
+			Verify: func(ctx context.Context, token string) ([]auth.Permission, error) {
+				var payload jwtPayload
+				if _, err := jwt.Verify([]byte(token), jwt.NewHS256(secret), &payload); err != nil {
+					return nil, xerrors.Errorf("JWT Verification failed: %w", err)
+				}
+				return payload.Allow, nil
+			},
+			Next: mux.ServeHTTP,
+		}
+
+		ctx := lcli.ReqContext(cctx)
+		srv := &http.Server{Handler: handler}
+		go func() {
+			<-ctx.Done()
+			fmt.Println("正在关闭钱包api服务...")
+			if err := srv.Shutdown(context.TODO()); err != nil {
+				fmt.Printf("关闭钱包api服务失败，err: %v\n", err)
+			}
+		}()
+
+		nl, err := net.Listen("tcp", cctx.String("listen"))
+		if err != nil {
+			fmt.Printf("监听 %s 失败，err: %v\n", cctx.String("listen"), err)
+			return err
+		}
+
+		fmt.Printf("钱包api服务已启动: http://%s/rpc/v0\n", nl.Addr())
+		fmt.Println("使用 'serve-wallet-api create-token' 生成访问token")
+		err = srv.Serve(nl)
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		return err
+	},
+}
+
+var walletAPITokenCmd = &cli.Command{
+	Name:  "create-token",
+	Usage: "生成钱包api的访问token",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "perm",
+			Usage: "token的权限，read, write, sign, admin 之一，包含更低级别的权限",
+			Value: string(lapi.PermSign),
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !passwdValid {
+			fmt.Println("密码错误.")
+			return fmt.Errorf("密码错误")
+		}
+
+		perm := auth.Permission(cctx.String("perm"))
+		idx := -1
+		for i, p := range lapi.AllPermissions {
+			if p == perm {
+				idx = i
+			}
+		}
+		if idx < 0 {
+			fmt.Printf("不合法的权限 %s\n", perm)
+			return xerrors.Errorf("unknown permission %s", perm)
+		}
+
+		secret, err := walletAPISecret()
+		if err != nil {
+			fmt.Printf("读取钱包api密钥失败，err: %v\n", err)
+			return err
+		}
+
+		token, err := jwt.Sign(&jwtPayload{Allow: lapi.AllPermissions[:idx+1]}, jwt.NewHS256(secret))
+		if err != nil {
+			return xerrors.Errorf("jwt sign: %w", err)
+		}
+
+		fmt.Println(string(token))
+		return nil
+	},
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletAPIPerms(t *testing.T) {
+	perms, err := parseWalletAPIPerms([]string{"WalletList=sign"})
+	assert.Nil(t, err)
+	assert.Equal(t, lapi.PermSign, perms["WalletList"])
+	assert.Equal(t, lapi.PermRead, perms["WalletHas"])
+
+	_, err = parseWalletAPIPerms([]string{"WalletFoo=read"})
+	assert.NotNil(t, err)
+	_, err = parseWalletAPIPerms([]string{"WalletList=root"})
+	assert.NotNil(t, err)
+
+	w := &walletAPI{perms: perms}
+	readCtx := auth.WithPerm(context.Background(), []auth.Permission{lapi.PermRead})
+	assert.Nil(t, w.checkPerm(readCtx, "WalletHas"))
+	assert.NotNil(t, w.checkPerm(readCtx, "WalletList"))
+	assert.NotNil(t, w.checkPerm(context.Background(), "WalletHas"))
+
+	// 未指定 --enable-export 时不允许导出私钥
+	_, err = w.WalletExport(auth.WithPerm(context.Background(), lapi.AllPermissions), address.TestAddress)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,532 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/firefly-wallet/db"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	actorstypes "github.com/filecoin-project/go-state-types/actors"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/builtin"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/v0api"
+	"github.com/filecoin-project/lotus/blockstore"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/adt"
+	lbuiltin "github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/multisig"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+)
+
+var withdrawAllTrackedFlag = &cli.BoolFlag{
+	Name:  "all-tracked",
+	Usage: "提现本地钱包中记录了矿工号的所有矿工",
+}
+
+var withdrawFromFileFlag = &cli.StringFlag{
+	Name:  "from-file",
+	Usage: "从文件读取要提现的矿工，每行 \"矿工号 [金额(FIL)]\"，金额为空或 all 时提现全部可用余额",
+}
+
+var withdrawLeaveFlag = &cli.StringFlag{
+	Name:  "leave",
+	Usage: "每个矿工保留的可用余额(FIL)",
+	Value: "0",
+}
+
+// withdrawRequest 一个矿工的提现请求，Amount 为 nil 时提现全部可用余额
+type withdrawRequest struct {
+	Miner  string
+	Amount *abi.TokenAmount
+}
+
+func (r withdrawRequest) requested() string {
+	if r.Amount == nil {
+		return "all"
+	}
+	return types.FIL(*r.Amount).String()
+}
+
+// readWithdrawFile 读取提现文件，# 开头的行为注释，矿工号和金额用空白或逗号分隔
+func readWithdrawFile(path string) ([]withdrawRequest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	var out []withdrawRequest
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		// 只有分隔符的行
+		if len(fields) == 0 {
+			continue
+		}
+		if line == 1 && strings.EqualFold(fields[0], "miner") {
+			continue
+		}
+
+		req := withdrawRequest{Miner: fields[0]}
+		if len(fields) > 1 && !strings.EqualFold(fields[1], "all") {
+			v, err := types.ParseFIL(fields[1])
+			if err != nil {
+				return nil, xerrors.Errorf("第 %d 行金额不正确: %w", line, err)
+			}
+			amt := abi.TokenAmount(v)
+			req.Amount = &amt
+		}
+		out = append(out, req)
+	}
+	return out, sc.Err()
+}
+
+// trackedMiners 本地钱包中记录的所有矿工号
+func trackedMiners() ([]withdrawRequest, error) {
+	infos, err := localAddresses(&sweepFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var out []withdrawRequest
+	for _, fai := range infos {
+		if fai.MinerId == "" || seen[fai.MinerId] {
+			continue
+		}
+		seen[fai.MinerId] = true
+		out = append(out, withdrawRequest{Miner: fai.MinerId})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Miner < out[j].Miner })
+	return out, nil
+}
+
+// withdrawAmount 按请求金额、保留余额和受益人限制计算实际提现金额，先从可用余额中扣除保留余额再按受益人额度截断
+func withdrawAmount(mi lapi.MinerInfo, epoch abi.ChainEpoch, requested *abi.TokenAmount, available, leave abi.TokenAmount) abi.TokenAmount {
+	amount := withdrawLimit(mi, big.Max(big.Sub(available, leave), big.Zero()), epoch)
+	if requested != nil && requested.LessThan(amount) {
+		amount = *requested
+	}
+	return amount
+}
+
+type withdrawJob struct {
+	withdrawRequest
+	Maddr     address.Address
+	Owner     address.Address
+	Available abi.TokenAmount
+	Planned   abi.TokenAmount
+	Withdrawn abi.TokenAmount
+	Cid       cid.Cid
+	Status    string
+}
+
+func planWithdraw(ctx context.Context, api v0api.FullNode, req withdrawRequest, leave abi.TokenAmount, epoch abi.ChainEpoch) *withdrawJob {
+	job := &withdrawJob{withdrawRequest: req, Available: big.Zero(), Planned: big.Zero(), Withdrawn: big.Zero()}
+
+	maddr, err := parseAddress(req.Miner)
+	if err != nil {
+		job.Status = fmt.Sprintf("矿工号不正确: %v", err)
+		return job
+	}
+	job.Maddr = maddr
+
+	mi, err := api.StateMinerInfo(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		job.Status = fmt.Sprintf("读取矿工信息失败: %v", err)
+		return job
+	}
+	available, err := api.StateMinerAvailableBalance(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		job.Status = fmt.Sprintf("读取可用余额失败: %v", err)
+		return job
+	}
+	job.Available = withdrawLimit(mi, available, epoch)
+	warnFeeDebt(ctx, api, maddr)
+
+	ms, err := isMultisig(ctx, api, mi.Owner)
+	if err != nil {
+		job.Status = fmt.Sprintf("读取owner失败: %v", err)
+		return job
+	}
+	if ms {
+		job.Status = fmt.Sprintf("owner是多签，请单独执行 withdraw %s", req.Miner)
+		return job
+	}
+	job.Owner, err = api.StateAccountKey(ctx, mi.Owner, types.EmptyTSK)
+	if err != nil {
+		job.Status = fmt.Sprintf("查询owner公钥地址失败: %v", err)
+		return job
+	}
+
+	job.Planned = withdrawAmount(mi, epoch, req.Amount, available, leave)
+	if job.Planned.IsZero() {
+		job.Status = "没有可提现余额"
+	}
+	return job
+}
+
+func (j *withdrawJob) message() (*types.Message, error) {
+	params, err := actors.SerializeParams(&miner2.WithdrawBalanceParams{
+		AmountRequested: j.Planned,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.Message{
+		To:     j.Maddr,
+		From:   j.Owner,
+		Value:  big.Zero(),
+		Method: builtin.MethodsMiner.WithdrawBalance,
+		Params: params,
+	}, nil
+}
+
+func printWithdrawJobs(jobs []*withdrawJob, done bool) error {
+	cols := []tablewriter.Column{
+		tablewriter.Col("Miner"),
+		tablewriter.Col("Owner"),
+		tablewriter.Col("Requested"),
+		tablewriter.Col("Available"),
+		tablewriter.Col("Planned"),
+	}
+	if done {
+		cols = append(cols, tablewriter.Col("Withdrawn"), tablewriter.Col("Message"))
+	}
+	cols = append(cols, tablewriter.NewLineCol("Status"))
+	tw := tablewriter.New(cols...)
+
+	planned, withdrawn := big.Zero(), big.Zero()
+	for _, j := range jobs {
+		row := map[string]interface{}{
+			"Miner":     j.Miner,
+			"Requested": j.requested(),
+			"Available": types.FIL(j.Available),
+			"Planned":   types.FIL(j.Planned),
+			"Status":    j.Status,
+		}
+		if j.Owner != address.Undef {
+			row["Owner"] = j.Owner
+		}
+		if done {
+			row["Withdrawn"] = types.FIL(j.Withdrawn)
+			if j.Cid != cid.Undef {
+				row["Message"] = j.Cid
+			}
+		}
+		planned = big.Add(planned, j.Planned)
+		withdrawn = big.Add(withdrawn, j.Withdrawn)
+		tw.Write(row)
+	}
+	if err := tw.Flush(os.Stdout); err != nil {
+		return err
+	}
+
+	if done {
+		fmt.Printf("\n合计计划提现 %s，实际提现 %s\n", types.FIL(planned), types.FIL(withdrawn))
+	} else {
+		fmt.Printf("\n共 %d 个矿工，合计计划提现 %s\n", len(jobs), types.FIL(planned))
+	}
+	return nil
+}
+
+// withdrawMany 多个矿工提现：统一预览确认，按owner分组依次发送，最后从回执读取实际提现金额
+func withdrawMany(cctx *cli.Context) error {
+	var reqs []withdrawRequest
+	var err error
+	if path := cctx.String(withdrawFromFileFlag.Name); path != "" {
+		reqs, err = readWithdrawFile(path)
+	} else {
+		reqs, err = trackedMiners()
+	}
+	if err != nil {
+		fmt.Println("读取提现列表失败,", err)
+		return err
+	}
+	if len(reqs) == 0 {
+		fmt.Println("没有要提现的矿工")
+		return nil
+	}
+
+	leave, err := types.ParseFIL(cctx.String(withdrawLeaveFlag.Name))
+	if err != nil {
+		fmt.Println("leave 不正确,", err)
+		return err
+	}
+
+	api, closer, err := getFullNodeAPI(cctx)
+	if err != nil {
+		fmt.Printf("连接FULLNODE_API_INFO api失败。%v\n", err)
+		return err
+	}
+	defer closer()
+	ctx := lcli.ReqContext(cctx)
+
+	head, err := api.ChainHead(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+
+	var jobs, todo []*withdrawJob
+	for _, req := range reqs {
+		job := planWithdraw(ctx, api, req, abi.TokenAmount(leave), head.Height())
+		jobs = append(jobs, job)
+		if job.Status == "" {
+			todo = append(todo, job)
+		}
+	}
+	if err := printWithdrawJobs(jobs, false); err != nil {
+		return err
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	if dryRun {
+		for _, j := range todo {
+			fmt.Printf("\n%s:\n", j.Miner)
+			msg, err := j.message()
+			if err == nil {
+				msg, err = estimateMessageGas(ctx, cctx, api, msg)
+			}
+			if err == nil {
+				err = simulateMessage(ctx, api, msg)
+			}
+			if err != nil {
+				fmt.Println(err)
+			}
+		}
+		return nil
+	}
+
+	if err := confirmSend(cctx); err != nil {
+		if err == errCanceled {
+			return nil
+		}
+		return err
+	}
+
+	// 同一个owner的消息依次发送，nonce连续；某条失败后跳过同一个owner的后续消息，避免nonce空洞
+	sort.SliceStable(todo, func(i, j int) bool { return todo[i].Owner.String() < todo[j].Owner.String() })
+	failedOwner := map[address.Address]bool{}
+	for _, j := range todo {
+		if failedOwner[j.Owner] {
+			j.Status = "跳过(同一owner的前一条消息失败)"
+			continue
+		}
+		msg, err := j.message()
+		if err == nil {
+			j.Cid, err = pushMessage(ctx, cctx, api, msg)
+		}
+		if err != nil {
+			j.Status = fmt.Sprintf("发送失败: %v", err)
+			failedOwner[j.Owner] = true
+			continue
+		}
+		fmt.Printf("%s 提现消息 %s\n", j.Miner, j.Cid)
+	}
+
+	for _, j := range todo {
+		if j.Cid == cid.Undef {
+			continue
+		}
+		lookup, err := waitMessage(ctx, cctx, api, j.Cid)
+		if err != nil {
+			j.Status = err.Error()
+			continue
+		}
+		// WithdrawBalance 返回实际提现的金额
+		var w abi.TokenAmount
+		if err := w.UnmarshalCBOR(bytes.NewReader(lookup.Receipt.Return)); err != nil {
+			j.Status = fmt.Sprintf("解析回执失败: %v", err)
+			continue
+		}
+		j.Withdrawn = w
+		j.Status = "ok"
+	}
+
+	fmt.Println()
+	return printWithdrawJobs(jobs, true)
+}
+
+// localMsigSigners 多签签名人中在本地钱包里的地址，返回签名人ID到公钥地址的映射，按签名人顺序
+func localMsigSigners(ctx context.Context, api v0api.FullNode, signers []address.Address) ([]address.Address, map[address.Address]address.Address) {
+	var ids []address.Address
+	keys := map[address.Address]address.Address{}
+	for _, s := range signers {
+		key, err := api.StateAccountKey(ctx, s, types.EmptyTSK)
+		if err != nil {
+			continue
+		}
+		if _, err := localdb.Get(db.KeyAddr, key.String()); err != nil {
+			continue
+		}
+		ids = append(ids, s)
+		keys[s] = key
+	}
+	return ids, keys
+}
+
+// msigWithdraw owner是多签时，用本地签名人发起提现提案，已有相同的提案时确认该提案。
+// anyAmount 为 true 时(没有指定提现金额)，该矿工任意金额的提现提案都视为相同
+func msigWithdraw(ctx context.Context, cctx *cli.Context, api v0api.FullNode, msig, maddr address.Address, amount abi.TokenAmount, anyAmount bool) error {
+	store := adt.WrapStore(ctx, cbor.NewCborStore(blockstore.NewAPIBlockstore(api)))
+	act, err := api.StateGetActor(ctx, msig, types.EmptyTSK)
+	if err != nil {
+		return xerrors.Errorf("读取多签 %s 失败: %w", msig, err)
+	}
+	mstate, err := multisig.Load(store, act)
+	if err != nil {
+		return xerrors.Errorf("读取多签 %s 状态失败: %w", msig, err)
+	}
+	threshold, err := mstate.Threshold()
+	if err != nil {
+		return err
+	}
+	signers, err := mstate.Signers()
+	if err != nil {
+		return err
+	}
+
+	local, keys := localMsigSigners(ctx, api, signers)
+	if len(local) == 0 {
+		return xerrors.Errorf("本地钱包中没有多签 %s 的签名人", msig)
+	}
+	if cctx.IsSet(msigFromFlag.Name) {
+		from, err := parseAddress(cctx.String(msigFromFlag.Name))
+		if err != nil {
+			return err
+		}
+		fromID, err := api.StateLookupID(ctx, from, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("查询 %s 失败: %w", from, err)
+		}
+		if _, ok := keys[fromID]; !ok {
+			return xerrors.Errorf("%s 不是多签 %s 的本地签名人", from, msig)
+		}
+		local = []address.Address{fromID}
+	}
+
+	minerID, err := api.StateLookupID(ctx, maddr, types.EmptyTSK)
+	if err != nil {
+		return err
+	}
+	params, err := actors.SerializeParams(&miner2.WithdrawBalanceParams{
+		AmountRequested: amount,
+	})
+	if err != nil {
+		return err
+	}
+
+	// 查找相同的提现提案
+	txid := int64(-1)
+	var txn multisig.Transaction
+	err = mstate.ForEachPendingTxn(func(id int64, t multisig.Transaction) error {
+		if txid >= 0 || t.To != minerID || t.Method != builtin.MethodsMiner.WithdrawBalance || !t.Value.IsZero() {
+			return nil
+		}
+		if anyAmount || bytes.Equal(t.Params, params) {
+			txid, txn = id, t
+		}
+		return nil
+	})
+	if err != nil {
+		return xerrors.Errorf("读取多签待确认提案失败: %w", err)
+	}
+
+	nv, err := api.StateNetworkVersion(ctx, types.EmptyTSK)
+	if err != nil {
+		return err
+	}
+	av, err := actorstypes.VersionForNetwork(nv)
+	if err != nil {
+		return err
+	}
+
+	var msg *types.Message
+	approvals := uint64(1)
+	if txid >= 0 {
+		approved := map[address.Address]bool{}
+		for _, a := range txn.Approved {
+			approved[a] = true
+		}
+		var signer address.Address
+		for _, s := range local {
+			if !approved[s] {
+				signer = s
+				break
+			}
+		}
+		if signer == address.Undef {
+			// 门限可能已经降到已确认数以下
+			if uint64(len(txn.Approved)) >= threshold {
+				fmt.Printf("多签 %s 已有提现提案 %d，确认数已达到门限 %d，其它签名人执行 msig approve 即可执行提案\n", msig, txid, threshold)
+				return nil
+			}
+			fmt.Printf("多签 %s 已有提现提案 %d，本地签名人都已确认，还需要 %d 个其它签名人确认\n", msig, txid, threshold-uint64(len(txn.Approved)))
+			return nil
+		}
+
+		var p miner2.WithdrawBalanceParams
+		if err := p.UnmarshalCBOR(bytes.NewReader(txn.Params)); err == nil {
+			fmt.Printf("多签 %s 已有提现提案 %d，提现 %s，由 %s 确认\n", msig, txid, types.FIL(p.AmountRequested), keys[signer])
+		}
+		msg, err = multisig.Message(av, keys[signer]).Approve(msig, uint64(txid), &multisig.ProposalHashData{
+			Requester: txn.Approved[0],
+			To:        txn.To,
+			Value:     txn.Value,
+			Method:    txn.Method,
+			Params:    txn.Params,
+		})
+		approvals = uint64(len(txn.Approved)) + 1
+	} else {
+		fmt.Printf("owner %s 是多签，由 %s 发起提现提案\n", msig, keys[local[0]])
+		msg, err = multisig.Message(av, keys[local[0]]).Propose(msig, maddr, big.Zero(), builtin.MethodsMiner.WithdrawBalance, params)
+	}
+	if err != nil {
+		return xerrors.Errorf("构造多签消息失败: %w", err)
+	}
+
+	msgCid, err := sendMessage(ctx, cctx, api, msg, false)
+	if err != nil || msgCid == cid.Undef {
+		return err
+	}
+
+	if approvals >= threshold {
+		fmt.Printf("消息 %s 发送后达到多签门限 %d，提现将执行\n", msgCid, threshold)
+	} else {
+		fmt.Printf("消息 %s 已发送，多签门限 %d，还需要 %d 个签名人确认\n", msgCid, threshold, threshold-approvals)
+	}
+	return nil
+}
+
+func isMultisig(ctx context.Context, api v0api.FullNode, addr address.Address) (bool, error) {
+	act, err := api.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		return false, err
+	}
+	return lbuiltin.IsMultisigActor(act.Code), nil
+}
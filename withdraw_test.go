@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	lapi "github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWithdrawFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "withdraw.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("miner,amount\n# comment\n,,\nf01000,100\nf01001\nf01002 all\n"), 0644))
+
+	reqs, err := readWithdrawFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, reqs, 3)
+	assert.Equal(t, "f01000", reqs[0].Miner)
+	assert.Equal(t, abi.TokenAmount(types.MustParseFIL("100")), *reqs[0].Amount)
+	assert.Nil(t, reqs[1].Amount)
+	assert.Equal(t, "all", reqs[2].requested())
+
+	assert.NoError(t, os.WriteFile(path, []byte("f01000 abc\n"), 0644))
+	_, err = readWithdrawFile(path)
+	assert.Error(t, err)
+}
+
+func TestWithdrawAmount(t *testing.T) {
+	available := abi.NewTokenAmount(100)
+	requested := abi.NewTokenAmount(30)
+
+	owner, _ := address.NewIDAddress(1000)
+	mi := lapi.MinerInfo{Owner: owner, Beneficiary: owner}
+
+	assert.Equal(t, available, withdrawAmount(mi, 10, nil, available, big.Zero()))
+	assert.Equal(t, abi.NewTokenAmount(90), withdrawAmount(mi, 10, nil, available, abi.NewTokenAmount(10)))
+	assert.Equal(t, requested, withdrawAmount(mi, 10, &requested, available, abi.NewTokenAmount(10)))
+	assert.Equal(t, big.Zero(), withdrawAmount(mi, 10, nil, available, abi.NewTokenAmount(200)))
+
+	// 先扣除保留余额再按受益人额度截断
+	mi.Beneficiary, _ = address.NewIDAddress(1001)
+	mi.BeneficiaryTerm = &miner.BeneficiaryTerm{Quota: abi.NewTokenAmount(10), UsedQuota: big.Zero(), Expiration: 100}
+	assert.Equal(t, abi.NewTokenAmount(5), withdrawAmount(mi, 10, nil, available, abi.NewTokenAmount(95)))
+	assert.Equal(t, abi.NewTokenAmount(10), withdrawAmount(mi, 10, nil, available, abi.NewTokenAmount(50)))
+}